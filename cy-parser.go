@@ -1,49 +1,882 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"cypress-parser/cy"
+	"cypress-parser/tbcs"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// Exit codes of the commands, 2 is used by the flag package for invalid flags.
+const (
+	exitFailure = 1 // invalid options, unreadable files, lint errors
+	exitParse   = 3 // the specs contain errors, nothing is imported
+	exitLogin   = 4 // login to TestBench CS failed, nothing is imported
+	exitPartial = 5 // some elements could not be imported, see the summary
+)
+
+// specFlags flags controlling which specs are parsed and how, shared by all commands.
+type specFlags struct {
+	verbose       *bool
+	cypressspecs  *string
+	cypresssuffix *string
+	include       *string
+	exclude       *string
+	epic          *string
+	hierarchy     *string
+	skipped       *string
+}
+
+func addSpecFlags(flags *flag.FlagSet) *specFlags {
+	return &specFlags{
+		verbose:       flags.Bool("v", false, "Verbose mode."),
+		cypressspecs:  flags.String("cy-specs", "./", "Comma separated Cypress spec folders."),
+		cypresssuffix: flags.String("cy-suffix", "func.spec.ts", "Cypress spec suffix to search for if -cy-include is not given."),
+		include: flags.String("cy-include", "", "Comma separated glob patterns of the specs relative to the spec folders, "+
+			"e.g. '**/*.cy.{js,ts}'. Patterns starting with '!' exclude specs."),
+		exclude: flags.String("cy-exclude", "", "Comma separated glob patterns of files not to parse, e.g. '**/fixtures/**'. "+
+			"node_modules folders are always skipped."),
+		epic: flags.String("epic", "Cypress-Tests", "TestBench CS epic name to import test cases to."),
+		hierarchy: flags.String("hierarchy", string(cy.HierarchyJoined), "Mapping of nested describe blocks: "+
+			"'joined' (user story named by all describe titles), 'nearest' (user story named by innermost describe) "+
+			"or 'epic' (outermost describe is the epic, inner describes the user story)."),
+		skipped: flags.String("skipped", string(cy.SkipManual), "Handling of skipped and pending tests: "+
+			"'manual' (imported as not automated test cases) or 'omit' (not imported)."),
+	}
+}
+
+// parseSpecs parses the specs selected by the flags, invalid flag values end
+// the program. The TBCS_AUTIDs of omitted skipped tests are returned too.
+func (f *specFlags) parseSpecs() ([]*cy.Epic, []string, []cy.Diagnostic) {
+	mapping, err := cy.ParseHierarchy(*f.hierarchy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+
+	skipPolicy, err := cy.ParseSkipPolicy(*f.skipped)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+
+	return cy.ParseSpecs(f.selection(), *f.epic, mapping, skipPolicy, *f.verbose)
+}
+
+// selection returns the spec files selected by the flags.
+func (f *specFlags) selection() *cy.SpecSelection {
+	selection := &cy.SpecSelection{
+		Include: cy.SplitPatterns(*f.include),
+		Exclude: cy.SplitPatterns(*f.exclude),
+	}
+	for _, root := range strings.Split(*f.cypressspecs, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			selection.Roots = append(selection.Roots, root)
+		}
+	}
+	if len(selection.Include) == 0 {
+		selection.Include = []string{cy.SuffixPattern(*f.cypresssuffix)}
+	}
+	return selection
+}
+
+// Built-in credentials, only accepted for a TestBench CS on the local machine.
+const (
+	defaultUser     = "admin"
+	defaultPassword = "password"
+)
+
+// Environment variables with the credentials.
+const (
+	userEnv     = "TBCS_USER"
+	passwordEnv = "TBCS_PASSWORD"
+)
+
+// secretFlags flags whose values are never printed.
+var secretFlags = map[string]bool{"password": true}
+
+// clientFlags flags with the credentials, TLS, retry and concurrency settings of the TestBench CS connection.
+type clientFlags struct {
+	user          *string
+	password      *string
+	passwordFile  *string
+	passwordStdin *bool
+	caFile        *string
+	certFile      *string
+	keyFile       *string
+	tlsMinVersion *string
+	insecure      *bool
+	retries       *int
+	retryMaxTime  *time.Duration
+	workers       *int
+	rateLimit     *float64
+	lockFile      *string
+	config        *configFlags
+}
+
+// addClientFlags adds the client flags, the configuration files selected by
+// config supply the values not given on the command line.
+func addClientFlags(flags *flag.FlagSet, config *configFlags) *clientFlags {
+	return &clientFlags{
+		config:        config,
+		user:          flags.String("user", "", "TestBench CS tenant admin name, default $"+userEnv+" or "+defaultUser+"."),
+		password:      flags.String("password", "", "Deprecated, use -password-file, -password-stdin or $"+passwordEnv+". TestBench CS tenant admin password."),
+		passwordFile:  flags.String("password-file", "", "File containing the TestBench CS password."),
+		passwordStdin: flags.Bool("password-stdin", false, "Reads the TestBench CS password from the first line of stdin."),
+		caFile:        flags.String("ca-file", "", "PEM file with additional CA certificates to verify the TestBench CS certificate."),
+		certFile:      flags.String("cert-file", "", "PEM file with a client certificate for TestBench CS, requires -key-file."),
+		keyFile:       flags.String("key-file", "", "PEM file with the key of the client certificate."),
+		tlsMinVersion: flags.String("tls-min-version", "1.2", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3."),
+		insecure:      flags.Bool("insecure", false, "Disables the verification of the TestBench CS certificate. Not recommended."),
+		retries:       flags.Int("retries", tbcs.DefaultRetryPolicy.MaxRetries, "Maximum retries of a TestBench CS request failed temporarily, 0 disables retries."),
+		retryMaxTime:  flags.Duration("retry-max-time", tbcs.DefaultRetryPolicy.MaxTime, "Maximum time to retry a TestBench CS request."),
+		workers:       flags.Int("workers", 4, "Number of test cases imported in parallel."),
+		rateLimit:     flags.Float64("rate-limit", 0, "Maximum TestBench CS requests per second, 0 is unlimited."),
+		lockFile: flags.String("lockfile", cy.DefaultLockFile, "Lock file mapping epics, user stories and TBCS_AUTIDs to TestBench CS ids, "+
+			"updated by the import. 'none' disables it. A lock file of another product is only replaced if given on the command line."),
+	}
+}
+
+// connection returns the connection to the host selected by the flags, only
+// with the client settings and the lock. Unavailable credentials, a built-in
+// user or password used for another host than localhost and an invalid lock
+// file end the program.
+func (f *clientFlags) connection(host string) *cy.Connection {
+	if f.config.commandLine["password"] {
+		fmt.Fprintf(os.Stderr, "Warning: -password is deprecated, the command line is visible to other users. "+
+			"Use -password-file, -password-stdin or $%s.\n", passwordEnv)
+	}
+	user, password, err := f.credentials()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Reading the password failed:", err)
+		os.Exit(exitFailure)
+	}
+	if !isLocalhost(host) {
+		if user == defaultUser {
+			fmt.Fprintf(os.Stderr, "Refusing to use the built-in user with %s, set the user with -user or $%s.\n", host, userEnv)
+			os.Exit(exitFailure)
+		}
+		if password == defaultPassword {
+			fmt.Fprintf(os.Stderr, "Refusing to use the built-in password with %s, set the password with -password-file, -password-stdin or $%s.\n", host, passwordEnv)
+			os.Exit(exitFailure)
+		}
+	}
+
+	var lock *cy.Lock
+	if f.useLock() {
+		if lock, err = cy.LoadLock(*f.lockFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitFailure)
+		}
+	}
+
+	retry := tbcs.DefaultRetryPolicy
+	retry.MaxRetries, retry.MaxTime = *f.retries, *f.retryMaxTime
+	return &cy.Connection{
+		User:     user,
+		Password: password,
+		TLS: &tbcs.TLSOptions{
+			CAFile:     *f.caFile,
+			CertFile:   *f.certFile,
+			KeyFile:    *f.keyFile,
+			MinVersion: *f.tlsMinVersion,
+			Insecure:   *f.insecure,
+		},
+		Retry:       &retry,
+		Workers:     *f.workers,
+		RateLimit:   *f.rateLimit,
+		Lock:        lock,
+		ReplaceLock: f.config.commandLine["lockfile"],
+	}
+}
+
+func (f *clientFlags) useLock() bool {
+	return *f.lockFile != "" && *f.lockFile != "none"
+}
+
+// saveLock writes the lock updated by an import, a failure is only reported.
+func (f *clientFlags) saveLock(connection *cy.Connection) {
+	if connection.Lock == nil {
+		return
+	}
+	if err := cy.SaveLock(*f.lockFile, connection.Lock); err != nil {
+		fmt.Fprintln(os.Stderr, "Saving the lock file failed:", err)
+	}
+}
+
+// credentials returns the user from -user, $TBCS_USER or the built-in one and
+// the password from the first source given: -password-file, -password-stdin,
+// $TBCS_PASSWORD, -password, the reporterOptions of the Cypress
+// configuration, the built-in one.
+func (f *clientFlags) credentials() (user, password string, err error) {
+	user = firstOf(*f.user, os.Getenv(userEnv), defaultUser)
+	switch {
+	case *f.passwordFile != "":
+		data, err := ioutil.ReadFile(*f.passwordFile)
+		if err != nil {
+			return "", "", err
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	case *f.passwordStdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	default:
+		password = firstOf(os.Getenv(passwordEnv), *f.password, f.config.password, defaultPassword)
+	}
+	if password == "" {
+		return "", "", errors.New("the password is empty")
+	}
+	return user, password, nil
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isLocalhost reports whether the URL refers to the local machine.
+func isLocalhost(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// printFlags prints the values of all flags, secret values are redacted.
+func printFlags(flags *flag.FlagSet) {
+	flags.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "********"
+		}
+		fmt.Print(f.Name, ": ", value, "\n")
+	})
+}
+
+// connectionFlags flags selecting the TestBench CS product and the client settings.
+type connectionFlags struct {
+	tbcshost      *string
+	workspaceName *string
+	productID     *int
+	*clientFlags
+}
+
+func addConnectionFlags(flags *flag.FlagSet, config *configFlags) *connectionFlags {
+	return &connectionFlags{
+		tbcshost:      flags.String("tbcs-host", "https://localhost", "TestBench CS host name to import test cases to."),
+		workspaceName: flags.String("workspace-name", "imbus", "TestBench CS workspace name to import test cases to."),
+		productID:     flags.Int("product-id", 1, "TestBench CS product id to import test cases to."),
+		clientFlags:   addClientFlags(flags, config),
+	}
+}
+
+// connection returns the connection selected by the flags.
+func (f *connectionFlags) connection() *cy.Connection {
+	connection := f.clientFlags.connection(*f.tbcshost)
+	connection.Host = *f.tbcshost
+	connection.Tenant = *f.workspaceName
+	connection.ProductID = *f.productID
+	return connection
+}
+
+// orphanFlags flags controlling the handling of test cases removed from the specs.
+type orphanFlags struct {
+	policy   *string
+	managed  *string
+	obsolete *string
+}
+
+func addOrphanFlags(flags *flag.FlagSet) *orphanFlags {
+	return &orphanFlags{
+		policy: flags.String("orphans", string(cy.OrphanReport), "Handling of managed test cases no longer found in the specs: "+
+			"'report', 'unautomate' (marked as not automated), 'move' (to the obsolete user story) or 'delete'."),
+		managed:  flags.String("managed", cy.DefaultManagedPattern, "Regular expression matching the external ids of test cases managed by the import."),
+		obsolete: flags.String("obsolete-story", cy.DefaultObsoleteUserStory, "User story within the epic given by -epic that orphaned test cases are moved to."),
+	}
+}
+
+// options returns the orphan options selected by the flags, invalid flag values end the program.
+func (f *orphanFlags) options(epic string) *cy.OrphanOptions {
+	policy, err := cy.ParseOrphanPolicy(*f.policy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+
+	managed, err := regexp.Compile(*f.managed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid managed pattern:", err)
+		os.Exit(exitFailure)
+	}
+
+	return &cy.OrphanOptions{Policy: policy, Managed: managed, Epic: epic, UserStory: *f.obsolete}
+}
+
+// parseOrExit parses the specs and ends the program if there are errors.
+func (f *specFlags) parseOrExit() ([]*cy.Epic, []string) {
+	epics, omitted, diagnostics := f.parseSpecs()
+	cy.PrintDiagnostics(os.Stderr, diagnostics)
+	if cy.HasErrors(diagnostics) {
+		fmt.Fprintln(os.Stderr, "Parsing failed, nothing is imported.")
+		os.Exit(exitParse)
+	}
+	return epics, omitted
+}
+
+// pathFlags flags with file or folder values. Relative paths in the project
+// configuration file are relative to the folder of the file.
+var pathFlags = map[string]bool{
+	"cy-specs": true, "password-file": true, "ca-file": true, "cert-file": true, "key-file": true, "plan": true, "out": true,
+	"cypress-config": true, "from": true, "lockfile": true,
+}
+
+// configFlags flags selecting the project configuration file, its profile and
+// the Cypress configuration file. The password of the Cypress configuration
+// is kept apart from -password, it is only used if no other password is given.
+type configFlags struct {
+	file        *string
+	profile     *string
+	cypress     *string
+	password    string
+	commandLine map[string]bool // flags given on the command line
+}
+
+func addConfigFlags(flags *flag.FlagSet) *configFlags {
+	return &configFlags{
+		file:    flags.String("config", cy.DefaultConfigFile, "Project configuration file with flag values and profiles, skipped if the default file does not exist."),
+		profile: flags.String("profile", "", "Profile of the project configuration file, e.g. staging or production."),
+		cypress: flags.String("cypress-config", "", "Cypress configuration file to take the reporterOptions and the spec folder from, "+
+			"by default cypress.config.* or cypress.json in the current folder. 'none' skips it."),
+	}
+}
+
+// apply sets the flags not given on the command line to the values of the
+// project configuration file, the remaining ones to the values of the Cypress
+// configuration file. Values of flags the command does not have are skipped,
+// so one file serves all commands. Invalid files and values end the program,
+// invalid reporter options of the Cypress configuration are only skipped. The
+// flags given on the command line are recorded before.
+func (f *configFlags) apply(flags *flag.FlagSet) {
+	f.commandLine = map[string]bool{}
+	flags.Visit(func(given *flag.Flag) {
+		f.commandLine[given.Name] = true
+	})
+	f.applyProjectConfig(flags)
+	f.applyCypressConfig(flags)
+}
+
+func (f *configFlags) applyProjectConfig(flags *flag.FlagSet) {
+	config, err := cy.LoadConfig(*f.file)
+	if os.IsNotExist(err) && !isSet(flags, "config") && *f.profile == "" {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Reading the configuration failed:", err)
+		os.Exit(exitFailure)
+	}
+	values, err := config.Values(*f.profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+	delete(values, "config")
+	delete(values, "profile")
+	warnUnknownFlags(*f.file, values)
+	setFlags(flags, *f.file, values, filepath.Dir(*f.file))
+}
+
+// commandFlags flags of the other commands the import does not have.
+var commandFlags = map[string]bool{"rules": true, "max-title-length": true, "pattern": true, "check": true, "plan": true, "from": true}
+
+// warnUnknownFlags warns about values of the file no command has a flag for,
+// e.g. misspelled ones.
+func warnUnknownFlags(file string, values map[string]string) {
+	importFlags := flag.NewFlagSet("import", flag.ContinueOnError)
+	addImportFlags(importFlags)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if importFlags.Lookup(name) == nil && !commandFlags[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "Warning: %s: skipping unknown parameter %q.\n", file, name)
+	}
+}
+
+func (f *configFlags) applyCypressConfig(flags *flag.FlagSet) {
+	file := *f.cypress
+	if file == "none" {
+		return
+	}
+	if file == "" {
+		if file = cy.FindCypressConfig("."); file == "" {
+			return
+		}
+	}
+	config, err := cy.LoadCypressConfig(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Reading the Cypress configuration failed:", err)
+		os.Exit(exitFailure)
+	}
+
+	options := config.ReporterOptions
+	setConnectionFlags(flags, file, map[string]string{
+		"tbcs-host":      options.ServerURL,
+		"workspace-name": options.Workspace,
+		"user":           options.Username,
+		"product-id":     options.ProductID,
+	})
+	if placeholder.MatchString(options.Password) {
+		fmt.Fprintf(os.Stderr, "Warning: %s: skipping placeholder %q for the password.\n", file, "********")
+	} else {
+		f.password = options.Password
+	}
+	setFlags(flags, file, map[string]string{"cy-specs": config.SpecRoot()}, "")
+	// the specs given by -cy-suffix or -cy-include are kept as they are
+	if !config.DeclaresSpecs() || isSet(flags, "cy-suffix") || isSet(flags, "cy-include") {
+		return
+	}
+	setFlags(flags, file, map[string]string{
+		"cy-include": strings.Join(config.SpecPatterns(), ","),
+		"cy-exclude": strings.Join(config.ExcludePatterns(), ","),
+	}, "")
+}
+
+// placeholder matches template values like "<user>" of a Cypress configuration.
+var placeholder = regexp.MustCompile(`^<.*>$`)
+
+// setConnectionFlags sets the connection flags not set yet to the reporter
+// options of the Cypress configuration. The options belong to the result
+// import, so empty, placeholder and invalid values are skipped with a
+// warning instead of ending the program, commands not connecting to
+// TestBench CS have to work with them too.
+func setConnectionFlags(flags *flag.FlagSet, file string, values map[string]string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := values[name]
+		if value == "" || isSet(flags, name) || flags.Lookup(name) == nil {
+			continue
+		}
+		shown := value
+		if secretFlags[name] {
+			shown = "********"
+		}
+		if placeholder.MatchString(value) {
+			fmt.Fprintf(os.Stderr, "Warning: %s: skipping placeholder %q for %s.\n", file, shown, name)
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: skipping invalid value %q for %s: %v\n", file, shown, name, err)
+		}
+	}
+}
+
+// setFlags sets the flags not set yet to the values read from the file.
+// Relative paths are resolved against the folder unless it is empty.
+func setFlags(flags *flag.FlagSet, file string, values map[string]string, folder string) {
+	for name, value := range values {
+		if isSet(flags, name) || flags.Lookup(name) == nil {
+			continue
+		}
+		if folder != "" && pathFlags[name] {
+			value = resolvePaths(folder, value)
+		}
+		if err := flags.Set(name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid value %q for %s: %v\n", file, value, name, err)
+			os.Exit(exitFailure)
+		}
+	}
+}
+
+// resolvePaths resolves the relative paths of a comma separated list against
+// the folder, 'none' is kept.
+func resolvePaths(folder, list string) string {
+	paths := strings.Split(list, ",")
+	for i, path := range paths {
+		if path = strings.TrimSpace(path); path != "" && path != "none" && !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(folder, path)
+		}
+	}
+	return strings.Join(paths, ",")
+}
+
+// isSet reports whether the flag was given on the command line or set from a file.
+func isSet(flags *flag.FlagSet, name string) (set bool) {
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// importFlags flags of the import, the default command.
+type importFlags struct {
+	spec       *specFlags
+	dryrun     *bool
+	out        *string
+	connection *connectionFlags
+	orphans    *orphanFlags
+	config     *configFlags
+}
+
+func addImportFlags(flags *flag.FlagSet) *importFlags {
+	config := addConfigFlags(flags)
+	return &importFlags{
+		spec:   addSpecFlags(flags),
+		dryrun: flags.Bool("dryrun", false, "Only parses the cypress specs and shows result. No import is done."),
+		out: flags.String("out", "", "File to save the parsed model to, as YAML if it ends with .yml or .yaml, as JSON otherwise. "+
+			"A JSON model can be imported with the import command."),
+		connection: addConnectionFlags(flags, config),
+		orphans:    addOrphanFlags(flags),
+		config:     config,
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			lint(os.Args[2:])
+			return
+		case "autid":
+			autid(os.Args[2:])
+			return
+		case "plan":
+			plan(os.Args[2:])
+			return
+		case "apply":
+			apply(os.Args[2:])
+			return
+		case "import":
+			importModel(os.Args[2:])
+			return
+		case "config":
+			showConfig(os.Args[2:])
+			return
+		}
+	}
+
 	// flags
-	verbose := flag.Bool("v", false, "Verbose mode.")
-	dryrun := flag.Bool("dryrun", false, "Only parses the cypress specs and shows result. No import is done.")
-	cypressspecs := flag.String("cy-specs", "./", "Cypress scpec folder.")
-	cypresssuffix := flag.String("cy-suffix", "func.spec.ts", "Cypress scpec suffix to search for.")
-	tbcshost := flag.String("tbcs-host", "https://localhost", "TestBench CS host name to import test cases to.")
-	workspaceName := flag.String("workspace-name", "imbus", "TestBench CS workspace name to import test cases to.")
-	productID := flag.Int("product-id", 1, "TestBench CS product id to import test cases to.")
-	user := flag.String("user", "admin", "TestBench CS tenant admin name.")
-	password := flag.String("password", "password", "TestBench CS tenant admin password.")
-	epic := flag.String("epic", "Cypress-Tests", "TestBench CS epic name to import test cases to.")
+	options := addImportFlags(flag.CommandLine)
+	spec, dryrun, out, connection, orphans := options.spec, options.dryrun, options.out, options.connection, options.orphans
 
 	flag.Usage = printUsage
 	flag.Parse()
+	options.config.apply(flag.CommandLine)
 
 	fmt.Print("\nRunning with:\n")
-	flag.VisitAll(func(f *flag.Flag) {
-		fmt.Print(f.Name, ": ", f.Value, "\n")
-	})
+	printFlags(flag.CommandLine)
 	fmt.Println()
 
+	orphanOptions := orphans.options(*spec.epic)
+
 	fmt.Println("Starting scan ...")
-	epics := cy.ParseSpecs(*cypressspecs, *cypresssuffix, *epic, *verbose)
+	epics, omitted, diagnostics := spec.parseSpecs()
+	orphanOptions.Omitted = omitted
 	if *dryrun {
 		cy.PrintResults(epics)
+	}
+	cy.PrintDiagnostics(os.Stderr, diagnostics)
+	if cy.HasErrors(diagnostics) {
+		fmt.Fprintln(os.Stderr, "Parsing failed, nothing is imported.")
+		os.Exit(exitParse)
+	}
+	if *out != "" {
+		if err := cy.SaveModel(*out, epics, omitted); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitFailure)
+		}
+		fmt.Println("Model saved to", *out)
+	}
+	if *dryrun {
 		os.Exit(0)
 	}
 
 	fmt.Println("Starting import ...")
-	cy.Import(*tbcshost, *workspaceName, *productID, *user, *password, epics, *verbose)
+	target := connection.connection()
+	summary, err := cy.Import(interruptContext(), target, epics, orphanOptions, *spec.verbose)
+	if err == nil {
+		connection.saveLock(target)
+	}
+	finish(summary, err)
+}
+
+// finish prints the summary of an import and ends the program with the
+// exit code matching the outcome.
+func finish(summary *cy.Summary, err error) {
+	if err != nil {
+		exitConnectionError(err)
+	}
+	fmt.Println()
+	summary.Print(os.Stdout)
+	if summary.HasFailures() {
+		fmt.Fprintln(os.Stderr, "Import failed partially.")
+		os.Exit(exitPartial)
+	}
 	fmt.Println("Done.")
 }
 
+// exitConnectionError ends the program after the import could not start.
+// Only a failed login request ends it with exitLogin, invalid client settings
+// and a lock file of another product are refused before the login.
+func exitConnectionError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	var lockError *cy.LockError
+	if errors.As(err, &lockError) {
+		fmt.Fprintln(os.Stderr, "Give the lock file with -lockfile on the command line to replace it, or use another one.")
+	}
+	var loginError *cy.LoginError
+	if errors.As(err, &loginError) {
+		os.Exit(exitLogin)
+	}
+	os.Exit(exitFailure)
+}
+
+// lint checks the specs against the TestBench CS import rules without importing anything.
+func lint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	spec := addSpecFlags(flags)
+	rules := cy.DefaultLintConfig()
+	flags.Var(rules, "rules", "Comma separated rule severities, e.g. 'no-steps=error,missing-autid=off'. "+
+		"Severities are 'error', 'warning' and 'off'.")
+	maxTitleLength := flags.Int("max-title-length", cy.DefaultMaxTitleLength, "Maximum length of epic, user story and test case names.")
+	config := addConfigFlags(flags)
+	flags.Usage = func() {
+		header := "Usage:\n" +
+			"  " + os.Args[0] + " lint <flags>\n\n" +
+			"Rules:\n"
+		fmt.Fprint(os.Stderr, header)
+		for _, r := range cy.LintRules {
+			fmt.Fprintf(os.Stderr, "  %-16s %s (default: %s)\n", r.Name, r.Description, r.Severity)
+		}
+		fmt.Fprint(os.Stderr, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	config.apply(flags)
+
+	epics, _, diagnostics := spec.parseSpecs()
+	diagnostics = cy.Lint(epics, diagnostics, rules, *maxTitleLength)
+	cy.PrintDiagnostics(os.Stdout, diagnostics)
+
+	errorCount, warningCount := 0, 0
+	for _, d := range diagnostics {
+		if d.Severity == cy.SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", errorCount, warningCount)
+	if errorCount > 0 {
+		os.Exit(exitFailure)
+	}
+}
+
+// autid generates missing TBCS_AUTIDs and writes them into the spec files.
+func autid(args []string) {
+	flags := flag.NewFlagSet("autid", flag.ExitOnError)
+	spec := addSpecFlags(flags)
+	pattern := flags.String("pattern", cy.DefaultAutIDPattern, "Pattern of generated ids. Placeholders: "+
+		"<EPIC>, <STORY>, <TEST>, <FILE> and a number like <NN>, padded to the count of N.")
+	check := flags.Bool("check", false, "Only reports tests without TBCS_AUTID and exits with 1 if there are any. No file is changed.")
+	config := addConfigFlags(flags)
+	flags.Parse(args)
+	config.apply(flags)
+
+	epics, omitted, diagnostics := spec.parseSpecs()
+	if cy.HasErrors(diagnostics) {
+		cy.PrintDiagnostics(os.Stderr, diagnostics)
+		fmt.Fprintln(os.Stderr, "Parsing failed, no file is changed.")
+		os.Exit(exitParse)
+	}
+
+	assignments, err := cy.GenerateAutIDs(epics, omitted, *pattern)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+
+	if *check {
+		for _, a := range assignments {
+			fmt.Printf("%s:%d:%d: test %q has no TBCS_AUTID\n", a.TestCase.File, a.TestCase.Line, a.TestCase.Column, a.TestCase.Title)
+		}
+		fmt.Printf("%d tests without TBCS_AUTID\n", len(assignments))
+		if len(assignments) > 0 {
+			os.Exit(exitFailure)
+		}
+		return
+	}
+
+	diagnostics, err = cy.WriteAutIDs(assignments)
+	cy.PrintDiagnostics(os.Stderr, diagnostics)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+	for _, a := range assignments {
+		if *spec.verbose {
+			fmt.Printf("%s:%d:%d: %s\n", a.TestCase.File, a.TestCase.Line, a.TestCase.Column, a.AutID)
+		}
+	}
+	fmt.Printf("%d TBCS_AUTIDs generated\n", len(assignments)-len(diagnostics))
+}
+
+// plan shows the changes an import would make in TestBench CS without changing anything.
+func plan(args []string) {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	spec := addSpecFlags(flags)
+	config := addConfigFlags(flags)
+	connection := addConnectionFlags(flags, config)
+	orphans := addOrphanFlags(flags)
+	out := flags.String("out", "", "File to save the plan to, it can be executed with the apply command.")
+	flags.Parse(args)
+	config.apply(flags)
+
+	orphanOptions := orphans.options(*spec.epic)
+	epics, omitted := spec.parseOrExit()
+	orphanOptions.Omitted = omitted
+	p, summary, err := cy.MakePlan(interruptContext(), connection.connection(), epics, orphanOptions)
+	if err != nil {
+		exitConnectionError(err)
+	}
+	cy.PrintPlan(os.Stdout, p)
+	if summary.HasFailures() {
+		fmt.Println()
+		summary.Print(os.Stdout)
+		fmt.Fprintln(os.Stderr, "Planning failed partially, the plan is incomplete and not saved.")
+		os.Exit(exitPartial)
+	}
+	if *out != "" {
+		if err := cy.SavePlan(*out, p); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitFailure)
+		}
+		fmt.Println("Plan saved to", *out)
+	}
+}
+
+// apply executes a plan saved by the plan command.
+func apply(args []string) {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "Verbose mode.")
+	planFile := flags.String("plan", "plan.json", "Plan file written by the plan command.")
+	config := addConfigFlags(flags)
+	client := addClientFlags(flags, config)
+	flags.Parse(args)
+	config.apply(flags)
+
+	p, err := cy.LoadPlan(*planFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+	fmt.Println("Starting import ...")
+	target := client.connection(p.Host)
+	summary, err := cy.Apply(interruptContext(), p, target, *verbose)
+	if err == nil {
+		client.saveLock(target)
+	}
+	finish(summary, err)
+}
+
+// importModel imports a model saved with -out instead of parsing the specs.
+func importModel(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "Verbose mode.")
+	from := flags.String("from", "model.json", "JSON model file saved with -out.")
+	epic := flags.String("epic", "Cypress-Tests", "TestBench CS epic of the obsolete user story, see -orphans.")
+	config := addConfigFlags(flags)
+	connection := addConnectionFlags(flags, config)
+	orphans := addOrphanFlags(flags)
+	flags.Parse(args)
+	config.apply(flags)
+
+	orphanOptions := orphans.options(*epic)
+	epics, omitted, err := cy.LoadModel(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+	orphanOptions.Omitted = omitted
+	fmt.Println("Starting import ...")
+	target := connection.connection()
+	summary, err := cy.Import(interruptContext(), target, epics, orphanOptions, *verbose)
+	if err == nil {
+		connection.saveLock(target)
+	}
+	finish(summary, err)
+}
+
+// showConfig prints the flag values of the import taken from the command
+// line and the project configuration file, secrets are redacted.
+func showConfig(args []string) {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	options := addImportFlags(flags)
+	flags.Parse(args)
+	options.config.apply(flags)
+	printFlags(flags)
+}
+
+// interruptContext returns a context canceled by the first Ctrl-C, so a
+// running import stops and closes its session. A second Ctrl-C ends the
+// program at once.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "Interrupted, stopping ...")
+		cancel()
+	}()
+	return ctx
+}
+
 func printUsage() {
 	header := "Usage:\n" +
-		"  " + os.Args[0] + " <flags>\n\n" +
+		"  " + os.Args[0] + " <flags>\n" +
+		"  " + os.Args[0] + " lint <flags>\n" +
+		"  " + os.Args[0] + " autid <flags>\n" +
+		"  " + os.Args[0] + " plan <flags>\n" +
+		"  " + os.Args[0] + " apply <flags>\n" +
+		"  " + os.Args[0] + " import <flags>\n" +
+		"  " + os.Args[0] + " config <flags>\n\n" +
 		"Flags:\n"
 	fmt.Fprint(os.Stderr, header)
 	flag.PrintDefaults()
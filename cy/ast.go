@@ -0,0 +1,310 @@
+package cy
+
+import "strings"
+
+type nodeKind int
+
+const (
+	suiteNode nodeKind = iota
+	testNode
+	hookNode
+	logNode
+	metaNode
+)
+
+// node element of the small syntax tree built from a spec file. Only calls
+// relevant for the import are kept, all other code is skipped but searched
+// for nested calls.
+type node struct {
+	kind     nodeKind
+	callee   string // called function, e.g. "describe", "it.skip", "cy.log" or "TBCS_AUTID"
+	title    string // value of the first argument
+	dynamic  bool   // the first argument is not a string literal
+	embeds   bool   // the title is a template literal with embedded ${...} expressions
+	skipped  bool   // skipped by ".skip" or an "x" prefix, or a test without body
+	only     bool   // exclusive by ".only"
+	quote    string // quote character of the title, empty if not a plain string
+	body     int    // rune offset of the opening brace of the callback body, -1 if none
+	line     int
+	column   int
+	children []*node
+}
+
+// recognized calls by callee name, including the Mocha aliases.
+// Modifiers like ".only" and ".skip" are handled by classifyCallee.
+var calleeKinds = map[string]nodeKind{
+	"describe":         suiteNode,
+	"context":          suiteNode,
+	"xdescribe":        suiteNode,
+	"xcontext":         suiteNode,
+	"it":               testNode,
+	"specify":          testNode,
+	"xit":              testNode,
+	"xspecify":         testNode,
+	"before":           hookNode,
+	"beforeEach":       hookNode,
+	"after":            hookNode,
+	"afterEach":        hookNode,
+	"cy.log":           logNode,
+	"TBCS_AUTID":       metaNode,
+	"TBCS_DESCRIPTION": metaNode,
+	"TBCS_CATEGORY":    metaNode,
+	"TBCS_EXPECTED":    metaNode,
+}
+
+// classifyCallee returns the node kind of a called function and whether it
+// is skipped or exclusive, e.g. "describe.only", "it.skip" or "xit".
+func classifyCallee(callee string) (kind nodeKind, skipped, only, known bool) {
+	base := callee
+	if strings.HasSuffix(callee, ".only") {
+		base, only = strings.TrimSuffix(callee, ".only"), true
+	} else if strings.HasSuffix(callee, ".skip") {
+		base, skipped = strings.TrimSuffix(callee, ".skip"), true
+	}
+	kind, known = calleeKinds[base]
+	if !known || (base != callee && kind != suiteNode && kind != testNode) {
+		return kind, false, false, false
+	}
+	if strings.HasPrefix(base, "x") && (kind == suiteNode || kind == testNode) {
+		skipped = true
+	}
+	return
+}
+
+var closers = map[string]string{"(": ")", "{": "}", "[": "]"}
+
+type astParser struct {
+	src          []rune
+	tokens       []token
+	pos          int
+	errs         []syntaxError
+	expected     []string // closing brackets of all open sequences
+	unterminated bool     // an unterminated bracket was reported already
+}
+
+// parseSource tokenizes a JavaScript/TypeScript source and returns the
+// top level nodes of its syntax tree.
+func parseSource(src string) (nodes []*node, errs []syntaxError) {
+	tokens, errs := tokenize(src)
+	p := &astParser{src: []rune(src), tokens: tokens}
+	nodes = p.parseSequence("")
+	return nodes, append(errs, p.errs...)
+}
+
+func (p *astParser) errorf(t token, message string) {
+	p.errs = append(p.errs, syntaxError{line: t.line, column: t.column, message: message})
+}
+
+// closeBracket consumes the closing bracket matching the given opening one.
+// Only the innermost unterminated bracket is reported, the enclosing ones
+// are unterminated as a consequence.
+func (p *astParser) closeBracket(open token) {
+	if p.isPunct(closers[open.text]) {
+		p.next()
+		p.unterminated = false
+	} else if !p.unterminated {
+		p.errorf(open, "unterminated '"+open.text+"', missing '"+closers[open.text]+"'")
+		p.unterminated = true
+	}
+}
+
+func (p *astParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *astParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *astParser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == text
+}
+
+// parseSequence collects nodes until the given closing bracket or the end of
+// input. The closing bracket itself is not consumed.
+func (p *astParser) parseSequence(closer string) (nodes []*node) {
+	p.expected = append(p.expected, closer)
+	defer func() { p.expected = p.expected[:len(p.expected)-1] }()
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenEOF:
+			return
+		case t.kind == tokenPunct && t.text == closer:
+			return
+		case t.kind == tokenPunct && closers[t.text] != "":
+			p.next()
+			nodes = append(nodes, p.parseSequence(closers[t.text])...)
+			p.closeBracket(t)
+		case t.kind == tokenPunct && (t.text == ")" || t.text == "}" || t.text == "]"):
+			// unbalanced bracket, leave it to an enclosing sequence expecting it
+			if p.isExpected(t.text) {
+				return
+			}
+			p.errorf(t, "unexpected '"+t.text+"'")
+			p.next()
+		case t.kind == tokenIdent && !p.afterMemberAccess():
+			nodes = append(nodes, p.parseCallChain()...)
+		default:
+			p.next()
+		}
+	}
+}
+
+// isExpected reports whether any open sequence is closed by the given bracket.
+func (p *astParser) isExpected(closer string) bool {
+	for _, c := range p.expected {
+		if c == closer {
+			return true
+		}
+	}
+	return false
+}
+
+// afterMemberAccess reports whether the current token is a property name,
+// e.g. "log" in "cy.get().log(".
+func (p *astParser) afterMemberAccess() bool {
+	if p.pos == 0 {
+		return false
+	}
+	prev := p.tokens[p.pos-1]
+	return prev.kind == tokenPunct && (prev.text == "." || prev.text == "?.")
+}
+
+// parseCallChain reads a dotted identifier chain like "cy.log" and, if it is
+// called, the call arguments.
+func (p *astParser) parseCallChain() (nodes []*node) {
+	start := p.next()
+	parts := []string{start.text}
+	for p.isPunct(".") && p.tokens[p.pos+1].kind == tokenIdent {
+		p.next()
+		parts = append(parts, p.next().text)
+	}
+	if !p.isPunct("(") {
+		return
+	}
+	open := p.next()
+
+	callee := strings.Join(parts, ".")
+	kind, skipped, only, known := classifyCallee(callee)
+	if !known {
+		nodes = p.parseSequence(")")
+		p.closeBracket(open)
+		return
+	}
+
+	n := &node{
+		kind:    kind,
+		callee:  callee,
+		skipped: skipped,
+		only:    only,
+		line:    start.line,
+		column:  start.column,
+		body:    -1,
+	}
+	if kind != hookNode {
+		if t := p.peek(); t.kind == tokenString {
+			n.quote = t.text[:1]
+		}
+		n.title, n.dynamic, n.embeds = p.parseArgument()
+	}
+	n.body = p.findBody()
+	// a test without callback is pending
+	if kind == testNode && p.isPunct(")") {
+		n.skipped = true
+	}
+	n.children = p.parseSequence(")")
+	p.closeBracket(open)
+	return []*node{n}
+}
+
+// findBody returns the rune offset of the opening brace of the callback
+// body within the remaining call arguments, e.g. of "() => {" or
+// "function () {". It returns -1 for callbacks with expression body.
+func (p *astParser) findBody() int {
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if t.kind != tokenPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[":
+			depth++
+		case "{":
+			if prev := p.tokens[i-1]; depth == 0 && prev.kind == tokenPunct && (prev.text == "=>" || prev.text == ")") {
+				return t.offset
+			}
+			depth++
+		case ")", "]", "}":
+			if depth == 0 {
+				return -1
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// parseArgument evaluates the next call argument. Strings and templates
+// concatenated with '+' are joined, any other expression is returned as
+// written in the source and reported as dynamic. Embeds reports
+// templates with embedded expressions, which are kept verbatim.
+func (p *astParser) parseArgument() (value string, dynamic, embeds bool) {
+	first := p.pos
+	depth := 0
+	for {
+		t := p.peek()
+		if t.kind == tokenEOF {
+			break
+		}
+		if t.kind == tokenPunct {
+			if closers[t.text] != "" {
+				depth++
+			} else if t.text == ")" || t.text == "}" || t.text == "]" {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if t.text == "," && depth == 0 {
+				break
+			}
+		}
+		p.next()
+	}
+	last := p.pos
+	if p.isPunct(",") {
+		p.next()
+	}
+	return p.evaluate(p.tokens[first:last])
+}
+
+func (p *astParser) evaluate(tokens []token) (value string, dynamic, embeds bool) {
+	if len(tokens) == 0 {
+		return "", false, false
+	}
+	var text strings.Builder
+	literal := true
+	for i, t := range tokens {
+		if i%2 == 0 {
+			if t.kind != tokenString && t.kind != tokenTemplate {
+				literal = false
+				break
+			}
+			text.WriteString(t.value)
+			embeds = embeds || t.embeds
+		} else if t.kind != tokenPunct || t.text != "+" {
+			literal = false
+			break
+		}
+	}
+	if literal && len(tokens)%2 == 1 {
+		return text.String(), false, embeds
+	}
+	return string(p.src[tokens[0].offset:tokens[len(tokens)-1].end]), true, false
+}
@@ -0,0 +1,201 @@
+package cy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyCallee(t *testing.T) {
+	tests := []struct {
+		callee  string
+		kind    nodeKind
+		skipped bool
+		only    bool
+		known   bool
+	}{
+		{"describe", suiteNode, false, false, true},
+		{"context.only", suiteNode, false, true, true},
+		{"describe.skip", suiteNode, true, false, true},
+		{"xdescribe", suiteNode, true, false, true},
+		{"it", testNode, false, false, true},
+		{"it.only", testNode, false, true, true},
+		{"specify.skip", testNode, true, false, true},
+		{"xit", testNode, true, false, true},
+		{"beforeEach", hookNode, false, false, true},
+		{"beforeEach.only", hookNode, false, false, false},
+		{"cy.log", logNode, false, false, true},
+		{"TBCS_AUTID", metaNode, false, false, true},
+		{"it.each", 0, false, false, false},
+		{"cy.get", 0, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.callee, func(t *testing.T) {
+			kind, skipped, only, known := classifyCallee(tt.callee)
+			if known != tt.known || (known && (kind != tt.kind || skipped != tt.skipped || only != tt.only)) {
+				t.Errorf("got kind %d skipped %t only %t known %t, want kind %d skipped %t only %t known %t",
+					kind, skipped, only, known, tt.kind, tt.skipped, tt.only, tt.known)
+			}
+		})
+	}
+}
+
+// summary of a node for comparisons, children are flattened in order.
+type nodeSummary struct {
+	callee  string
+	title   string
+	dynamic bool
+	embeds  bool
+	skipped bool
+	only    bool
+	quote   string
+	line    int
+	column  int
+	depth   int
+}
+
+func summarize(nodes []*node, depth int) (summaries []nodeSummary) {
+	for _, n := range nodes {
+		summaries = append(summaries, nodeSummary{n.callee, n.title, n.dynamic, n.embeds, n.skipped, n.only, n.quote, n.line, n.column, depth})
+		summaries = append(summaries, summarize(n.children, depth+1)...)
+	}
+	return
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []nodeSummary
+	}{
+		{
+			"quotes",
+			"describe('a', () => {\n  it(\"b\", () => {})\n  it(`c`, () => {})\n})",
+			[]nodeSummary{
+				{callee: "describe", title: "a", quote: "'", line: 1, column: 1},
+				{callee: "it", title: "b", quote: `"`, line: 2, column: 3, depth: 1},
+				{callee: "it", title: "c", line: 3, column: 3, depth: 1},
+			},
+		},
+		{
+			"escapes",
+			`it('it\'s ä', () => {})`,
+			[]nodeSummary{{callee: "it", title: "it's ä", quote: "'", line: 1, column: 1}},
+		},
+		{
+			"call over several lines",
+			"it(\n  'a' +\n    \"b\",\n  function () {\n    cy.log('c')\n  }\n)",
+			[]nodeSummary{
+				{callee: "it", title: "ab", quote: "'", line: 1, column: 1},
+				{callee: "cy.log", title: "c", quote: "'", line: 5, column: 5, depth: 1},
+			},
+		},
+		{
+			"only and skip chains",
+			"describe.only('a', () => {\n  it.skip('b', () => {})\n  xit('c', () => {})\n  it.only('d', () => {})\n})",
+			[]nodeSummary{
+				{callee: "describe.only", title: "a", only: true, quote: "'", line: 1, column: 1},
+				{callee: "it.skip", title: "b", skipped: true, quote: "'", line: 2, column: 3, depth: 1},
+				{callee: "xit", title: "c", skipped: true, quote: "'", line: 3, column: 3, depth: 1},
+				{callee: "it.only", title: "d", only: true, quote: "'", line: 4, column: 3, depth: 1},
+			},
+		},
+		{
+			"pending test",
+			"it('a')",
+			[]nodeSummary{{callee: "it", title: "a", skipped: true, quote: "'", line: 1, column: 1}},
+		},
+		{
+			"dynamic title",
+			"it(name + '!', () => {})",
+			[]nodeSummary{{callee: "it", title: "name + '!'", dynamic: true, line: 1, column: 1}},
+		},
+		{
+			"template expression",
+			"it(`user ${name}`, () => {})",
+			[]nodeSummary{{callee: "it", title: "user ${name}", embeds: true, line: 1, column: 1}},
+		},
+		{
+			"regexp with parenthesis",
+			"it('a', () => {\n  cy.contains(/\\(/)\n  cy.log('b')\n})",
+			[]nodeSummary{
+				{callee: "it", title: "a", quote: "'", line: 1, column: 1},
+				{callee: "cy.log", title: "b", quote: "'", line: 3, column: 3, depth: 1},
+			},
+		},
+		{
+			"division is no regexp",
+			"it('a', () => {\n  const x = (1) / 2; cy.log('b' /* / */)\n})",
+			[]nodeSummary{
+				{callee: "it", title: "a", quote: "'", line: 1, column: 1},
+				{callee: "cy.log", title: "b", quote: "'", line: 2, column: 22, depth: 1},
+			},
+		},
+		{
+			"nested in other calls",
+			"Cypress._.each([1, 2], (n) => {\n  it('a', () => {})\n})",
+			[]nodeSummary{{callee: "it", title: "a", quote: "'", line: 2, column: 3}},
+		},
+		{
+			"member is no call",
+			"cy.get('x').it('a')",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, errs := parseSource(tt.src)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if got := summarize(nodes, 0); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSourceBody(t *testing.T) {
+	tests := []struct {
+		src  string
+		body int
+	}{
+		{"it('a', () => {})", 14},
+		{"it('a', function () {})", 20},
+		{"it('a', () => cy.log('b'))", -1},
+		{"it('a')", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			nodes, _ := parseSource(tt.src)
+			if len(nodes) != 1 {
+				t.Fatalf("got %d nodes, want 1", len(nodes))
+			}
+			if nodes[0].body != tt.body {
+				t.Errorf("got body %d, want %d", nodes[0].body, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseSourceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []syntaxError
+	}{
+		{"unterminated string", "it('a, () => {})", []syntaxError{{1, 4, "unterminated string"}, {1, 3, "unterminated '(', missing ')'"}}},
+		{"unterminated comment", "it('a', () => {}) /* b", []syntaxError{{1, 19, "unterminated comment"}}},
+		{"unterminated template", "it(`a, () => {})", []syntaxError{{1, 4, "unterminated template literal"}, {1, 3, "unterminated '(', missing ')'"}}},
+		{"unterminated regexp", "it('a', () => {\n  cy.contains(/a)\n})", []syntaxError{{2, 15, "unterminated regular expression"}, {2, 14, "unterminated '(', missing ')'"}}},
+		{"unterminated call", "describe('a', () => {\n  it('b', () => {\n})", []syntaxError{{1, 21, "unterminated '{', missing '}'"}}},
+		{"unexpected bracket", "it('a', () => {})\n}", []syntaxError{{2, 1, "unexpected '}'"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseSource(tt.src)
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("got errors %v, want %v", errs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package cy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultAutIDPattern pattern used to generate missing TBCS_AUTIDs.
+const DefaultAutIDPattern = "CY-<STORY>-<NN>"
+
+// AutIDAssignment generated TBCS_AUTID for a test case without one.
+type AutIDAssignment struct {
+	TestCase *TestCase
+	AutID    string
+}
+
+var numberPlaceholder = regexp.MustCompile("<N+>")
+
+// GenerateAutIDs generates a unique TBCS_AUTID for every test case without
+// one. The pattern may contain the placeholders <EPIC>, <STORY>, <TEST> and
+// <FILE>, which are replaced by the upper case name of the element, and must
+// contain a number placeholder like <NN>, which is replaced by the next free
+// number, padded to the count of N. Existing TBCS_AUTIDs are never changed,
+// so generating again for the same specs results in the same ids. The
+// TBCS_AUTIDs of tests not in the epics, e.g. of omitted skipped tests, are
+// given by reserved and not generated again.
+func GenerateAutIDs(epics []*Epic, reserved []string, pattern string) (assignments []*AutIDAssignment, err error) {
+	if len(numberPlaceholder.FindAllString(pattern, -1)) != 1 {
+		return nil, fmt.Errorf("pattern %q must contain exactly one number placeholder like <NN>", pattern)
+	}
+
+	used := map[string]bool{}
+	for _, autID := range reserved {
+		used[autID] = true
+	}
+	for _, e := range epics {
+		for _, us := range e.UserStories {
+			for _, tc := range us.TestCases {
+				used[tc.TestCaseDetails.ExternalID.Value] = true
+			}
+		}
+	}
+
+	for _, e := range epics {
+		for _, us := range e.UserStories {
+			for _, tc := range us.TestCases {
+				if tc.TestCaseDetails.ExternalID.Value != "" {
+					continue
+				}
+				autID := nextAutID(expandAutIDPattern(pattern, e, us, tc), used)
+				used[autID] = true
+				assignments = append(assignments, &AutIDAssignment{TestCase: tc, AutID: autID})
+			}
+		}
+	}
+	return
+}
+
+func expandAutIDPattern(pattern string, epic *Epic, userStory *UserStory, testCase *TestCase) string {
+	file := testCase.File[strings.LastIndexAny(testCase.File, `/\`)+1:]
+	if i := strings.Index(file, "."); i > 0 {
+		file = file[:i]
+	}
+	return strings.NewReplacer(
+		"<EPIC>", autIDSlug(epic.Name),
+		"<STORY>", autIDSlug(userStory.Name),
+		"<TEST>", autIDSlug(testCase.Title),
+		"<FILE>", autIDSlug(file),
+	).Replace(pattern)
+}
+
+// nextAutID replaces the number placeholder by the number following the
+// highest number already used with the same prefix and suffix.
+func nextAutID(pattern string, used map[string]bool) string {
+	loc := numberPlaceholder.FindStringIndex(pattern)
+	prefix, suffix, digits := pattern[:loc[0]], pattern[loc[1]:], loc[1]-loc[0]-2
+
+	highest := 0
+	existing := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + "([0-9]+)" + regexp.QuoteMeta(suffix) + "$")
+	for autID := range used {
+		if match := existing.FindStringSubmatch(autID); match != nil {
+			if number, err := strconv.Atoi(match[1]); err == nil && number > highest {
+				highest = number
+			}
+		}
+	}
+	return fmt.Sprintf("%s%0*d%s", prefix, digits, highest+1, suffix)
+}
+
+// autIDSlug converts a name to upper case letters and digits separated by '-'.
+func autIDSlug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToUpper(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// WriteAutIDs inserts a TBCS_AUTID call as first statement into the body of
+// each assigned test. The rest of the files is left untouched. Tests without
+// block body, e.g. "it('...', () => cy.log('...'))", can not be changed and
+// are returned as diagnostics.
+func WriteAutIDs(assignments []*AutIDAssignment) (diagnostics []Diagnostic, err error) {
+	byFile := map[string][]*AutIDAssignment{}
+	var files []string
+	for _, a := range assignments {
+		if a.TestCase.body < 0 {
+			diagnostics = append(diagnostics, Diagnostic{
+				File:     a.TestCase.File,
+				Line:     a.TestCase.Line,
+				Column:   a.TestCase.Column,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("test %q has no block body, add TBCS_AUTID('%s') manually", a.TestCase.Title, a.AutID),
+			})
+			continue
+		}
+		if byFile[a.TestCase.File] == nil {
+			files = append(files, a.TestCase.File)
+		}
+		byFile[a.TestCase.File] = append(byFile[a.TestCase.File], a)
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return diagnostics, err
+		}
+		content = insertAutIDs(content, byFile[file])
+		if err := ioutil.WriteFile(file, content, 0644); err != nil {
+			return diagnostics, err
+		}
+	}
+	return diagnostics, nil
+}
+
+// insertAutIDs adds the TBCS_AUTID calls to a spec file, matching its line
+// endings, indentation, quotes and use of semicolons.
+func insertAutIDs(content []byte, assignments []*AutIDAssignment) []byte {
+	src := []rune(string(content))
+	newline := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	terminator := ""
+	if regexp.MustCompile(`(?m);\r?$`).Match(content) {
+		terminator = ";"
+	}
+
+	// insert from the end, so the offsets of the other tests stay valid
+	sort.Slice(assignments, func(i, j int) bool {
+		return assignments[i].TestCase.body > assignments[j].TestCase.body
+	})
+	for _, a := range assignments {
+		quote := a.TestCase.quote
+		if quote == "" {
+			quote = "'"
+		}
+		call := "TBCS_AUTID(" + quote + a.AutID + quote + ")"
+
+		pos := a.TestCase.body + 1
+		next := pos
+		for next < len(src) && unicode.IsSpace(src[next]) && src[next] != '\n' {
+			next++
+		}
+		var insert string
+		if next < len(src) && (src[next] == '\n' || src[next] == '\r') {
+			insert = newline + bodyIndent(src, a.TestCase.body) + call + terminator
+		} else if next < len(src) && src[next] == '}' {
+			insert = " " + call + terminator + " "
+		} else {
+			insert = " " + call + ";"
+		}
+		src = append(src[:pos], append([]rune(insert), src[pos:]...)...)
+	}
+	return []byte(string(src))
+}
+
+// bodyIndent returns the indentation of the first statement of a block body,
+// or the indentation of the line of its opening brace increased by one level
+// for empty bodies.
+func bodyIndent(src []rune, brace int) string {
+	pos := brace + 1
+	for pos < len(src) && unicode.IsSpace(src[pos]) {
+		pos++
+	}
+	if pos < len(src) && src[pos] != '}' {
+		start := pos
+		for start > 0 && src[start-1] != '\n' {
+			start--
+		}
+		return string(src[start:pos])
+	}
+
+	start := brace
+	for start > 0 && src[start-1] != '\n' {
+		start--
+	}
+	end := start
+	for end < brace && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	indent := string(src[start:end])
+	if strings.HasPrefix(indent, "\t") {
+		return indent + "\t"
+	}
+	return indent + "  "
+}
@@ -0,0 +1,43 @@
+package cy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateAutIDs(t *testing.T) {
+	testCase := func(title, autID string) *TestCase {
+		return &TestCase{Title: title, File: "e2e/login.cy.js", TestCaseDetails: &TestCasePatch{ExternalID: &ExternalID{Value: autID}}}
+	}
+	epics := []*Epic{{Name: "Epic", UserStories: []*UserStory{
+		{Name: "Login", TestCases: []*TestCase{testCase("a", "CY-LOGIN-01"), testCase("b", ""), testCase("c", "")}},
+		{Name: "Logout", TestCases: []*TestCase{testCase("d", "")}},
+	}}}
+	tests := []struct {
+		name     string
+		reserved []string
+		autIDs   []string
+	}{
+		{"none reserved", nil, []string{"CY-LOGIN-02", "CY-LOGIN-03", "CY-LOGOUT-01"}},
+		{"omitted tests", []string{"CY-LOGIN-04", "CY-LOGOUT-01"}, []string{"CY-LOGIN-05", "CY-LOGIN-06", "CY-LOGOUT-02"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments, err := GenerateAutIDs(epics, tt.reserved, DefaultAutIDPattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var autIDs []string
+			for _, a := range assignments {
+				autIDs = append(autIDs, a.AutID)
+			}
+			if !reflect.DeepEqual(autIDs, tt.autIDs) {
+				t.Errorf("got %q, want %q", autIDs, tt.autIDs)
+			}
+		})
+	}
+
+	if _, err := GenerateAutIDs(epics, nil, "CY-<STORY>"); err == nil {
+		t.Errorf("got no error for a pattern without number placeholder")
+	}
+}
@@ -0,0 +1,106 @@
+package cy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DefaultConfigFile project configuration file used if none is given.
+const DefaultConfigFile = ".tbcs.json"
+
+// Config project configuration file with flag values, e.g.
+//
+//	{
+//	  "defaults": {"cy-specs": "tests", "cy-suffix": ".js"},
+//	  "profiles": {
+//	    "staging": {"tbcs-host": "https://staging.example.com", "product-id": 5, "password": "${TBCS_STAGING_PASSWORD}"}
+//	  }
+//	}
+//
+// The defaults apply to all profiles, a profile overrides them.
+type Config struct {
+	File     string                            `json:"-"`
+	Defaults map[string]interface{}            `json:"defaults"`
+	Profiles map[string]map[string]interface{} `json:"profiles"`
+}
+
+// LoadConfig reads a project configuration file.
+func LoadConfig(file string) (*Config, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{File: file}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return config, nil
+}
+
+// ProfileNames returns the names of all profiles sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Values returns the flag values of the profile, only the defaults if the
+// profile name is empty. Environment variables like ${NAME} or $NAME in
+// strings are replaced by their values, unset variables are an error. $$ is
+// a literal $.
+func (c *Config) Values(profile string) (map[string]string, error) {
+	sources := []map[string]interface{}{c.Defaults}
+	if profile != "" {
+		values, found := c.Profiles[profile]
+		if !found {
+			return nil, fmt.Errorf("%s: unknown profile %q, available profiles: %v", c.File, profile, c.ProfileNames())
+		}
+		sources = append(sources, values)
+	}
+
+	result := map[string]string{}
+	for _, source := range sources {
+		for name, value := range source {
+			s, err := configValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", c.File, name, err)
+			}
+			result[name] = s
+		}
+	}
+	return result, nil
+}
+
+// configValue returns a JSON value as flag value with environment variables expanded.
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		var missing []string
+		expanded := os.Expand(v, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			value, found := os.LookupEnv(name)
+			if !found {
+				missing = append(missing, name)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return "", fmt.Errorf("environment variable %s is not set", missing[0])
+		}
+		return expanded, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unsupported value %v, expected a string, number or boolean", value)
+}
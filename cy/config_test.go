@@ -0,0 +1,38 @@
+package cy
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestConfigValue(t *testing.T) {
+	os.Setenv("CY_PARSER_TEST", "secret")
+	defer os.Unsetenv("CY_PARSER_TEST")
+	tests := []struct {
+		value interface{}
+		want  string
+		err   bool
+	}{
+		{"plain", "plain", false},
+		{"${CY_PARSER_TEST}", "secret", false},
+		{"a-$CY_PARSER_TEST-b", "a-secret-b", false},
+		{"pa$$word", "pa$word", false},
+		{"$$$$", "$$", false},
+		{"$${CY_PARSER_TEST}", "${CY_PARSER_TEST}", false},
+		{"$$$CY_PARSER_TEST", "$secret", false},
+		{"${CY_PARSER_UNSET}", "", true},
+		{5.0, "5", false},
+		{0.5, "0.5", false},
+		{true, "true", false},
+		{[]interface{}{"a"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			value, err := configValue(tt.value)
+			if (err != nil) != tt.err || value != tt.want {
+				t.Errorf("got %q, %v, want %q, error %t", value, err, tt.want, tt.err)
+			}
+		})
+	}
+}
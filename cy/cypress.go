@@ -0,0 +1,356 @@
+package cy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CypressConfigFiles names of Cypress configuration files, searched in this
+// order. cypress.json is used up to Cypress 9, the others since Cypress 10.
+var CypressConfigFiles = []string{
+	"cypress.config.ts", "cypress.config.js", "cypress.config.mjs", "cypress.config.cjs", "cypress.json",
+}
+
+// CypressConfig settings of a Cypress configuration file used by the import.
+// IntegrationFolder, TestFiles and IgnoreTestFiles are set by cypress.json,
+// SpecPattern and ExcludeSpecPattern of the e2e testing type by the
+// configuration files of Cypress 10 and later. Settings not found are empty,
+// the Cypress defaults are not filled in.
+type CypressConfig struct {
+	File               string
+	IntegrationFolder  string
+	TestFiles          []string
+	IgnoreTestFiles    []string
+	SpecPattern        []string
+	ExcludeSpecPattern []string
+	ReporterOptions    ReporterOptions
+}
+
+// ReporterOptions connection settings of the TestBench CS result reporter.
+type ReporterOptions struct {
+	ServerURL string
+	Workspace string
+	Username  string
+	Password  string
+	ProductID string
+}
+
+// FindCypressConfig returns the Cypress configuration file in the folder, an
+// empty name if there is none.
+func FindCypressConfig(folder string) string {
+	for _, name := range CypressConfigFiles {
+		file := filepath.Join(folder, name)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// LoadCypressConfig reads a Cypress configuration file. JavaScript and
+// TypeScript files are not executed, only the literal values of the object
+// passed to defineConfig or exported are read. Computed values, e.g. from
+// process.env, are skipped.
+func LoadCypressConfig(file string) (*CypressConfig, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if strings.HasSuffix(file, ".json") {
+		if err := json.Unmarshal(content, &values); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	} else if values, err = parseConfigObject(string(content)); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	config := &CypressConfig{
+		File:              file,
+		IntegrationFolder: configString(values["integrationFolder"]),
+		TestFiles:         configStrings(values["testFiles"]),
+		IgnoreTestFiles:   configStrings(values["ignoreTestFiles"]),
+	}
+	if e2e, ok := values["e2e"].(map[string]interface{}); ok {
+		config.SpecPattern = configStrings(e2e["specPattern"])
+		config.ExcludeSpecPattern = configStrings(e2e["excludeSpecPattern"])
+	}
+	if options, ok := values["reporterOptions"].(map[string]interface{}); ok {
+		config.ReporterOptions = ReporterOptions{
+			ServerURL: configString(options["serverUrl"]),
+			Workspace: configString(options["workspace"]),
+			Username:  configString(options["username"]),
+			Password:  configString(options["password"]),
+			ProductID: configString(options["productId"]),
+		}
+	}
+	return config, nil
+}
+
+// configString returns a string or number value, "" for anything else.
+func configString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// configStrings returns a string or an array of strings as slice.
+func configStrings(value interface{}) (values []string) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// parseConfigObject reads the configuration object of a Cypress 10+
+// configuration file, e.g. `export default defineConfig({...})`,
+// `module.exports = {...}` or `const config = {...}; export default config`.
+func parseConfigObject(src string) (map[string]interface{}, error) {
+	tokens, errs := tokenize(src)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%d:%d: %s", errs[0].line, errs[0].column, errs[0].message)
+	}
+	start := findConfigObject(tokens)
+	if start < 0 {
+		return nil, fmt.Errorf("no configuration object found")
+	}
+	p := &objectParser{tokens: tokens, pos: start}
+	value, _ := p.parseValue()
+	object, _ := value.(map[string]interface{})
+	return object, nil
+}
+
+// findConfigObject returns the index of the opening brace of the configuration object, -1 if not found.
+func findConfigObject(tokens []token) int {
+	isPunct := func(i int, text string) bool {
+		return i < len(tokens) && tokens[i].kind == tokenPunct && tokens[i].text == text
+	}
+	isIdent := func(i int, text string) bool {
+		return i < len(tokens) && tokens[i].kind == tokenIdent && tokens[i].text == text
+	}
+	// variable returns the object assigned to the variable, e.g. `const config: Cypress.ConfigOptions = {`
+	variable := func(name string) int {
+		for j := 0; j+1 < len(tokens); j++ {
+			if (isIdent(j, "const") || isIdent(j, "let") || isIdent(j, "var")) && isIdent(j+1, name) {
+				for k := j + 2; k+1 < len(tokens) && !isPunct(k, ";"); k++ {
+					if isPunct(k, "=") && isPunct(k+1, "{") {
+						return k + 1
+					}
+				}
+			}
+		}
+		return -1
+	}
+	for i := range tokens {
+		switch {
+		case isIdent(i, "defineConfig") && isPunct(i+1, "(") && isPunct(i+2, "{"):
+			return i + 2
+		case isIdent(i, "module") && isPunct(i+1, ".") && isIdent(i+2, "exports") && isPunct(i+3, "="):
+			if isPunct(i+4, "{") {
+				return i + 4
+			}
+			if i+4 < len(tokens) && tokens[i+4].kind == tokenIdent && variable(tokens[i+4].text) >= 0 {
+				return variable(tokens[i+4].text)
+			}
+		case isIdent(i, "export") && isIdent(i+1, "default"):
+			if isPunct(i+2, "{") {
+				return i + 2
+			}
+			if i+2 < len(tokens) && tokens[i+2].kind == tokenIdent && variable(tokens[i+2].text) >= 0 {
+				return variable(tokens[i+2].text)
+			}
+		}
+	}
+	return -1
+}
+
+// objectParser reads JavaScript object and array literals. Values other than
+// literals, e.g. function calls and variables, are skipped.
+type objectParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *objectParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *objectParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *objectParser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == text
+}
+
+// parseValue reads a value up to the next ',' or closing bracket, false if it
+// is no literal.
+func (p *objectParser) parseValue() (interface{}, bool) {
+	t := p.peek()
+	switch {
+	case p.isPunct("{"):
+		return p.parseObject(), true
+	case p.isPunct("["):
+		return p.parseArray(), true
+	case t.kind == tokenString || (t.kind == tokenTemplate && !strings.Contains(t.text, "${")):
+		p.next()
+		if p.atValueEnd() {
+			return t.value, true
+		}
+	case t.kind == tokenNumber:
+		p.next()
+		if number, err := strconv.ParseFloat(t.text, 64); err == nil && p.atValueEnd() {
+			return number, true
+		}
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		p.next()
+		if p.atValueEnd() {
+			return t.text == "true", true
+		}
+	}
+	p.skipValue()
+	return nil, false
+}
+
+func (p *objectParser) atValueEnd() bool {
+	return p.isPunct(",") || p.isPunct("}") || p.isPunct("]") || p.peek().kind == tokenEOF
+}
+
+// skipValue skips the tokens up to the next ',' or closing bracket outside of nested brackets.
+func (p *objectParser) skipValue() {
+	depth := 0
+	for p.peek().kind != tokenEOF {
+		t := p.peek()
+		if t.kind == tokenPunct {
+			switch t.text {
+			case "(", "{", "[":
+				depth++
+			case ")", "}", "]":
+				if depth == 0 {
+					return
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					return
+				}
+			}
+		}
+		p.next()
+	}
+}
+
+func (p *objectParser) parseObject() map[string]interface{} {
+	object := map[string]interface{}{}
+	p.next()
+	for p.peek().kind != tokenEOF && !p.isPunct("}") {
+		key := p.next()
+		name := key.text
+		if key.kind == tokenString {
+			name = key.value
+		}
+		switch {
+		case key.kind == tokenPunct:
+			// spread or computed key
+			p.skipValue()
+		case p.isPunct(":"):
+			p.next()
+			if value, ok := p.parseValue(); ok {
+				object[name] = value
+			}
+		default:
+			// shorthand property or method like setupNodeEvents(on, config) {...}
+			p.skipValue()
+		}
+		if p.isPunct(",") {
+			p.next()
+		}
+	}
+	p.next()
+	return object
+}
+
+func (p *objectParser) parseArray() []interface{} {
+	array := []interface{}{}
+	p.next()
+	for p.peek().kind != tokenEOF && !p.isPunct("]") {
+		if value, ok := p.parseValue(); ok {
+			array = append(array, value)
+		}
+		if p.isPunct(",") {
+			p.next()
+		}
+	}
+	p.next()
+	return array
+}
+
+// cypressJSON reports whether the configuration is from Cypress 9 or older.
+func (c *CypressConfig) cypressJSON() bool {
+	return strings.HasSuffix(c.File, ".json")
+}
+
+// SpecRoot returns the folder the spec patterns are relative to: the
+// integration folder up to Cypress 9, the project folder since Cypress 10.
+func (c *CypressConfig) SpecRoot() string {
+	folder := filepath.Dir(c.File)
+	switch {
+	case c.IntegrationFolder != "":
+		return filepath.Join(folder, c.IntegrationFolder)
+	case c.cypressJSON():
+		return filepath.Join(folder, "cypress", "integration")
+	}
+	return folder
+}
+
+// DeclaresSpecs reports whether the configuration selects the specs with
+// testFiles or specPattern.
+func (c *CypressConfig) DeclaresSpecs() bool {
+	if c.cypressJSON() {
+		return len(c.TestFiles) > 0
+	}
+	return len(c.SpecPattern) > 0
+}
+
+// SpecPatterns returns the patterns of the specs, empty if the configuration
+// declares none.
+func (c *CypressConfig) SpecPatterns() []string {
+	if c.cypressJSON() {
+		return c.TestFiles
+	}
+	return c.SpecPattern
+}
+
+// ExcludePatterns returns the patterns of files that are no specs, the
+// Cypress default if the configuration has none.
+func (c *CypressConfig) ExcludePatterns() []string {
+	switch {
+	case c.cypressJSON() && len(c.IgnoreTestFiles) > 0:
+		return c.IgnoreTestFiles
+	case !c.cypressJSON() && len(c.ExcludeSpecPattern) > 0:
+		return c.ExcludeSpecPattern
+	}
+	return []string{"*.hot-update.js"}
+}
@@ -1,23 +1,32 @@
 package cy
 
+// Test step blocks of a structured test case.
+const (
+	PreparationBlock = "Preparation"
+	TestBlock        = "Test"
+	CleanupBlock     = "Cleanup"
+)
+
 // TestStep importable test step.
 type TestStep struct {
-	TestStepBlock string `json:"testStepBlock"`
-	Description   string `json:"description"`
-}
-
-type testStepCreatedResponse struct {
-	EventID    int `json:"eventId"`
-	TestStepID int `json:"testStepId"`
+	TestStepBlock  string `json:"testStepBlock"`
+	Description    string `json:"description"`
+	ExpectedResult string `json:"expectedResult,omitempty"`
 }
 
 // TestCase importable test case.
 type TestCase struct {
-	UserStroyID     int    `json:"userStoryId"`
 	Name            string `json:"name"`
-	TestCaseType    string `json:"testCaseType"`
 	TestSteps       []*TestStep
 	TestCaseDetails *TestCasePatch
+	Categories      []string `json:"-"`
+	Skipped         bool     `json:"-"`
+	Title           string   `json:"-"` // title given in the spec, without suite titles
+	File            string   `json:"-"` // spec file and position of the test
+	Line            int      `json:"-"`
+	Column          int      `json:"-"`
+	quote           string   // quote character of the title in the spec
+	body            int      // rune offset of the test body in the spec, -1 if none
 }
 
 // TestCasePatch extened test case data
@@ -39,88 +48,14 @@ type ExternalID struct {
 	Value string `json:"value"`
 }
 
-type testCaseCreatedResponse struct {
-	EventID    int `json:"eventId"`
-	TestCaseID int `json:"testCaseId"`
-}
-
-type testCaseUpdateErrorResponse struct {
-	FailureType string `json:"failureType"`
-	Message     string `json:"message"`
-}
-
 // UserStory importable user story.
 type UserStory struct {
-	EpicID    int    `json:"epicId"`
 	Name      string `json:"name"`
 	TestCases []*TestCase
 }
 
-type userStoryCreatedResponse struct {
-	EventID     int `json:"eventId"`
-	UserStoryID int `json:"userStoryId"`
-}
-
 // Epic importable epic.
 type Epic struct {
 	Name        string `json:"name"`
 	UserStories []*UserStory
 }
-
-type epicCreatedResponse struct {
-	EventID int `json:"eventId"`
-	EpicID  int `json:"epicId"`
-}
-
-type elements struct {
-	Elements []*elementResponses `json:"elements"`
-}
-type elementResponses struct {
-	TestCaseSummary *testCaseSummary `json:"TestCaseSummary"`
-}
-
-type testCaseSummary struct {
-	Name string `json:"name"`
-	Tbid string `json:"tbid"`
-	ID   int    `json:"id"`
-}
-
-type getTestCaseResponse struct {
-	ProductID    int           `json:"productId"`
-	EpicID       int           `json:"epicId"`
-	UserStoryID  int           `json:"userStoryId"`
-	ID           int           `json:"id"`
-	Name         string        `json:"name"`
-	Description  string        `json:"description"`
-	TestSequence *testSequence `json:"testSequence"`
-}
-
-type testSequence struct {
-	TestStepBlocks []*testStepBlock `json:"testStepBlocks"`
-}
-
-type testStepBlock struct {
-	ID    int     `json:"id"`
-	Name  string  `json:"name"`
-	Steps []*step `json:"steps"`
-}
-
-type step struct {
-	ID          int    `json:"id"`
-	Description string `json:"description"`
-}
-
-type loginData struct {
-	Force    bool   `json:"force"`
-	Tenant   string `json:"tenantName"`
-	User     string `json:"login"`
-	Password string `json:"password"`
-}
-
-type loginResponse struct {
-	GlobalRoles     []string `json:"globalRoles"`
-	SessionToken    string   `json:"sessionToken"`
-	TenantID        int      `json:"tenantId"`
-	UserID          int      `json:"userId"`
-	VideoLinkServer string   `json:"videoLinkServer"`
-}
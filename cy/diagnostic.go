@@ -0,0 +1,73 @@
+package cy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Severity of a diagnostic.
+type Severity string
+
+const (
+	// SeverityError problem that prevents a correct import.
+	SeverityError Severity = "error"
+	// SeverityWarning problem that is worth a look but does not prevent the import.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic problem found while parsing the spec files. Line and column
+// are 1-based, a line of 0 refers to the whole file, a diagnostic without
+// file to no file in particular. Diagnostics with a rule name can be
+// configured by the lint command, see LintRules.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Rule     string
+	Message  string
+}
+
+// String formats the diagnostic compiler style, e.g.
+// "tests/login.spec.ts:12:3: error: it() outside of describe".
+func (d Diagnostic) String() string {
+	message := d.Message
+	if d.Rule != "" {
+		message += " [" + d.Rule + "]"
+	}
+	if d.File == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, message)
+	}
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, message)
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintDiagnostics writes one line per diagnostic.
+func PrintDiagnostics(w io.Writer, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		fmt.Fprintln(w, d)
+	}
+}
+
+// sortDiagnostics orders the diagnostics of a file by position.
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+}
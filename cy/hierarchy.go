@@ -0,0 +1,324 @@
+package cy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hierarchy defines how nested describe blocks are mapped onto epics and user stories.
+type Hierarchy string
+
+const (
+	// HierarchyJoined imports all tests into the default epic, the user story
+	// is named by the joined titles of all enclosing describe blocks.
+	HierarchyJoined Hierarchy = "joined"
+	// HierarchyNearest imports all tests into the default epic, the user story
+	// is named by the innermost describe block only.
+	HierarchyNearest Hierarchy = "nearest"
+	// HierarchyEpic maps the outermost describe block to an epic and the joined
+	// titles of the inner describe blocks to a user story. Tests placed directly
+	// in the outermost describe block get a user story named like the epic.
+	HierarchyEpic Hierarchy = "epic"
+)
+
+// Hierarchies lists all supported hierarchy mappings.
+var Hierarchies = []Hierarchy{HierarchyJoined, HierarchyNearest, HierarchyEpic}
+
+// ParseHierarchy returns the hierarchy mapping with the given name.
+func ParseHierarchy(name string) (Hierarchy, error) {
+	for _, h := range Hierarchies {
+		if string(h) == name {
+			return h, nil
+		}
+	}
+	return "", fmt.Errorf("unknown hierarchy %q, valid values are: %s", name, hierarchyNames())
+}
+
+func hierarchyNames() string {
+	names := make([]string, len(Hierarchies))
+	for i, h := range Hierarchies {
+		names[i] = string(h)
+	}
+	return strings.Join(names, ", ")
+}
+
+// location returns the epic and user story name for a test nested in the
+// given describe block titles.
+func (h Hierarchy) location(defaultEpic string, suites []string) (epicName, userStoryName string) {
+	switch h {
+	case HierarchyNearest:
+		return defaultEpic, suites[len(suites)-1]
+	case HierarchyEpic:
+		if len(suites) == 1 {
+			return suites[0], suites[0]
+		}
+		return suites[0], strings.Join(suites[1:], " ")
+	default:
+		return defaultEpic, strings.Join(suites, " ")
+	}
+}
+
+// modelBuilder assembles the import elements from the syntax trees of all
+// spec files. Epics and user stories with the same name are merged, so
+// several files or top level describe blocks may contribute to them.
+type modelBuilder struct {
+	hierarchy   Hierarchy
+	skipPolicy  SkipPolicy
+	defaultEpic string
+	fileName    string
+	epics       []*Epic
+	omitted     []string // external ids of omitted tests
+	diagnostics []Diagnostic
+}
+
+func (b *modelBuilder) report(n *node, severity Severity, message string) {
+	b.diagnostics = append(b.diagnostics, Diagnostic{
+		File:     b.fileName,
+		Line:     n.line,
+		Column:   n.column,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+func (b *modelBuilder) warn(n *node, message string) {
+	b.report(n, SeverityWarning, message)
+}
+
+// violate reports a lint rule violation. It is only a warning for the
+// import, the lint command applies the configured severity.
+func (b *modelBuilder) violate(n *node, rule, message string) {
+	b.diagnostics = append(b.diagnostics, Diagnostic{
+		File:     b.fileName,
+		Line:     n.line,
+		Column:   n.column,
+		Severity: SeverityWarning,
+		Rule:     rule,
+		Message:  message,
+	})
+}
+
+func (b *modelBuilder) epic(name string) *Epic {
+	for _, e := range b.epics {
+		if e.Name == name {
+			return e
+		}
+	}
+	e := &Epic{
+		Name: name,
+	}
+	b.epics = append(b.epics, e)
+	return e
+}
+
+func (b *modelBuilder) userStory(epic *Epic, name string) *UserStory {
+	for _, us := range epic.UserStories {
+		if us.Name == name {
+			return us
+		}
+	}
+	us := &UserStory{
+		Name: name,
+	}
+	epic.UserStories = append(epic.UserStories, us)
+	return us
+}
+
+// scope state inherited from the enclosing describe blocks of a spec file.
+type scope struct {
+	suites     []string // titles of the enclosing describe blocks
+	skipped    bool     // tests are skipped by an enclosing describe block
+	categories []string // categories declared on spec file or describe level
+	hooks      hooks    // steps of the hooks of all enclosing levels
+}
+
+// hooks steps logged by the hooks of a scope, ordered as cypress runs them.
+type hooks struct {
+	before     []*TestStep // outermost first
+	beforeEach []*TestStep // outermost first
+	afterEach  []*TestStep // innermost first
+	after      []*TestStep // innermost first
+}
+
+// nested returns the scope for the children of a describe block.
+func (s scope) nested(n *node) scope {
+	suites := make([]string, len(s.suites), len(s.suites)+1)
+	copy(suites, s.suites)
+	return scope{
+		suites:     append(suites, n.title),
+		skipped:    s.skipped || n.skipped,
+		categories: s.categories,
+		hooks:      s.hooks,
+	}
+}
+
+// withHooks adds the hooks declared on a level to the hooks of the enclosing levels.
+func (h hooks) withHooks(nodes []*node) hooks {
+	var level hooks
+	for _, n := range nodes {
+		if n.kind != hookNode {
+			continue
+		}
+		switch n.callee {
+		case "before":
+			level.before = append(level.before, collectHookSteps(n.children)...)
+		case "beforeEach":
+			level.beforeEach = append(level.beforeEach, collectHookSteps(n.children)...)
+		case "afterEach":
+			level.afterEach = append(level.afterEach, collectHookSteps(n.children)...)
+		case "after":
+			level.after = append(level.after, collectHookSteps(n.children)...)
+		}
+	}
+	return hooks{
+		before:     concatSteps(h.before, level.before),
+		beforeEach: concatSteps(h.beforeEach, level.beforeEach),
+		afterEach:  concatSteps(level.afterEach, h.afterEach),
+		after:      concatSteps(level.after, h.after),
+	}
+}
+
+// preparation returns copies of the steps for the preparation block of a test.
+func (h hooks) preparation() []*TestStep {
+	return copySteps(concatSteps(h.before, h.beforeEach), PreparationBlock)
+}
+
+// cleanup returns copies of the steps for the cleanup block of a test.
+func (h hooks) cleanup() []*TestStep {
+	return copySteps(concatSteps(h.afterEach, h.after), CleanupBlock)
+}
+
+// collectHookSteps returns the steps logged anywhere within a hook body.
+func collectHookSteps(nodes []*node) (steps []*TestStep) {
+	for _, n := range nodes {
+		switch {
+		case n.kind == logNode:
+			steps = append(steps, newTestStep("", n.title))
+		case n.callee == "TBCS_EXPECTED" && len(steps) > 0:
+			steps[len(steps)-1].ExpectedResult = n.title
+		}
+		steps = append(steps, collectHookSteps(n.children)...)
+	}
+	return
+}
+
+func concatSteps(a, b []*TestStep) []*TestStep {
+	return append(append([]*TestStep{}, a...), b...)
+}
+
+func copySteps(steps []*TestStep, block string) (copies []*TestStep) {
+	for _, ts := range steps {
+		c := *ts
+		c.TestStepBlock = block
+		copies = append(copies, &c)
+	}
+	return
+}
+
+// addNodes walks a syntax tree and adds all tests found. Categories and hooks
+// declared on the same level apply to all tests of that level, no matter if
+// they are declared before or after the tests.
+func (b *modelBuilder) addNodes(nodes []*node, s scope) {
+	s.categories = appendCategories(s.categories, nodes)
+	s.hooks = s.hooks.withHooks(nodes)
+	for _, n := range nodes {
+		if n.only {
+			b.violate(n, RuleOnly, n.callee+"() found, all other tests will not run in cypress")
+		}
+		if n.dynamic && (n.kind == suiteNode || n.kind == testNode) {
+			b.warn(n, n.callee+"() title is not a string literal, the source text is used as name")
+		}
+		if n.embeds && (n.kind == suiteNode || n.kind == testNode) {
+			b.warn(n, n.callee+"() title contains template expressions, they are kept as ${...} in the name")
+		}
+		if (n.kind == suiteNode || n.kind == testNode) && strings.TrimSpace(n.title) == "" {
+			b.violate(n, RuleEmptyName, n.callee+"() has an empty title")
+		}
+		switch n.kind {
+		case suiteNode:
+			b.addNodes(n.children, s.nested(n))
+		case testNode:
+			if len(s.suites) == 0 {
+				b.report(n, SeverityError, n.callee+"() outside of describe")
+				continue
+			}
+			if (s.skipped || n.skipped) && b.skipPolicy == SkipOmit {
+				if autID := autIDOf(n.children); autID != "" {
+					b.omitted = append(b.omitted, autID)
+				}
+				continue
+			}
+			epicName, userStoryName := b.hierarchy.location(b.defaultEpic, s.suites)
+			userStory := b.userStory(b.epic(epicName), userStoryName)
+			tc := b.createTestCaseFromNode(n, userStory)
+			tc.Categories = mergeCategories(s.categories, tc.Categories)
+			tc.TestSteps = append(append(s.hooks.preparation(), tc.TestSteps...), s.hooks.cleanup()...)
+			if s.skipped || n.skipped {
+				tc.Skipped = true
+				tc.TestCaseDetails.IsAutomated = false
+			}
+			userStory.TestCases = append(userStory.TestCases, tc)
+		case metaNode:
+			if n.callee != "TBCS_CATEGORY" {
+				b.warn(n, n.callee+"() outside of a test is ignored")
+			}
+		case hookNode:
+			// already handled for the whole level
+		default:
+			b.addNodes(n.children, s)
+		}
+	}
+}
+
+// autIDOf returns the TBCS_AUTID found within a test body, "" if there is none.
+func autIDOf(nodes []*node) string {
+	for _, n := range nodes {
+		switch n.kind {
+		case metaNode:
+			if n.callee == "TBCS_AUTID" {
+				return n.title
+			}
+		case suiteNode, testNode, hookNode:
+			// ignored inside of a test
+		default:
+			if autID := autIDOf(n.children); autID != "" {
+				return autID
+			}
+		}
+	}
+	return ""
+}
+
+// appendCategories adds the values of all TBCS_CATEGORY calls of a level.
+func appendCategories(categories []string, nodes []*node) []string {
+	var declared []string
+	for _, n := range nodes {
+		if n.kind == metaNode && n.callee == "TBCS_CATEGORY" {
+			declared = append(declared, splitCategories(n.title)...)
+		}
+	}
+	return mergeCategories(categories, declared)
+}
+
+// splitCategories splits a comma separated list of categories.
+func splitCategories(value string) (categories []string) {
+	for _, c := range strings.Split(value, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			categories = append(categories, c)
+		}
+	}
+	return
+}
+
+// mergeCategories returns a new list containing the categories of both lists
+// without duplicates.
+func mergeCategories(a, b []string) (categories []string) {
+	seen := map[string]bool{}
+	for _, c := range append(append([]string{}, a...), b...) {
+		if !seen[c] {
+			seen[c] = true
+			categories = append(categories, c)
+		}
+	}
+	return
+}
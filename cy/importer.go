@@ -2,337 +2,604 @@ package cy
 
 import (
 	"bytes"
-	"crypto/tls"
-	"encoding/json"
+	"context"
+	"cypress-parser/tbcs"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// Import starts the import into TestBench CS.
-func Import(host, tenantName string, productID int, user, password string, epics []*Epic, verbose bool) {
-	// disable certificate checks
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-
-	token, tenantID := login(host, tenantName, user, password)
-
-	createTestCases(tenantID, productID, epics, host, token, verbose)
+// importer imports into TestBench CS and keeps track of the outcome.
+type importer struct {
+	client  *tbcs.Client
+	verbose bool
+	summary *Summary
+	out     io.Writer
+	workers int
+	lock    *Lock
+}
 
-	return
+// Connection TestBench CS product to import to and the credentials of the
+// user. TLS is optional, certificates are verified by default. Retry is
+// optional too, tbcs.DefaultRetryPolicy is used without. Lock is optional,
+// it is updated by Import and Apply.
+type Connection struct {
+	Host      string
+	Tenant    string
+	ProductID int
+	User      string
+	Password  string
+	TLS       *tbcs.TLSOptions
+	Retry     *tbcs.RetryPolicy
+	Workers   int     // test cases imported in parallel, 1 if not set
+	RateLimit float64 // requests per second, unlimited if 0
+	Lock      *Lock
+	// ReplaceLock replaces a lock of another host, workspace or product,
+	// otherwise the import is refused with a *LockError
+	ReplaceLock bool
 }
 
-func login(host, tenantName, user, password string) (token string, tenantID int) {
-	data := &loginData{
-		Force:    true,
-		Tenant:   tenantName,
-		User:     user,
-		Password: password,
+// Import starts the import into TestBench CS. Orphaned test cases are
+// handled if orphan options are given. A failed login is returned as
+// *LoginError. Invalid client settings, e.g. an unreadable CA file, and a
+// lock of another product as *LockError are returned before logging in.
+// Failures of single elements are listed in the summary, the
+// import goes on with the elements not depending on them. The session is
+// closed at the end, also if the context is canceled.
+func Import(ctx context.Context, connection *Connection, epics []*Epic, orphans *OrphanOptions, verbose bool) (*Summary, error) {
+	im, err := login(ctx, connection, verbose)
+	if err != nil {
+		return nil, err
 	}
-	jsonValue, _ := json.Marshal(data)
-	fmt.Println("Login with: ", data.User)
+	defer im.logout()
 
-	request, err := http.NewRequest(http.MethodPost, host+"/api/tenants/login/session", bytes.NewBuffer(jsonValue))
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	response, err := http.DefaultClient.Do(request)
+	plan := im.makePlan(ctx, connection.Tenant, epics, orphans)
+	im.applyPlan(ctx, plan)
+	return im.result(), nil
+}
 
+// Apply executes a plan made by MakePlan, usually loaded from a file. Host,
+// tenant and product are taken from the plan, only the credentials and TLS
+// options from the connection. The test cases and steps are expected to be
+// unchanged since the plan was made. Errors are reported like by Import.
+func Apply(ctx context.Context, plan *Plan, connection *Connection, verbose bool) (*Summary, error) {
+	target := *connection
+	target.Host, target.Tenant, target.ProductID = plan.Host, plan.Tenant, plan.ProductID
+	im, err := login(ctx, &target, verbose)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-		return
+		return nil, err
 	}
+	defer im.logout()
 
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 201 {
-		fmt.Fprintln(os.Stderr, "Login failed with: ", response.Status, " Response: ", string(result))
-	}
-
-	var responseData loginResponse
-	err = json.Unmarshal(result, &responseData)
-	token = responseData.SessionToken
-	tenantID = responseData.TenantID
-
-	return
+	im.applyPlan(ctx, plan)
+	return im.result(), nil
 }
 
-func createTestCases(tenantID, productID int, epics []*Epic, host, sessionToken string, verbose bool) {
-	for _, v := range epics {
-		if verbose {
-			fmt.Println("Creating Epic: ", v.Name)
-		}
-		epicID := createEpic(tenantID, productID, v, host, sessionToken)
-		for _, v := range v.UserStories {
-			if verbose {
-				fmt.Println("  Creating User Story: ", v.Name)
-			}
-			userStoryID := createUserStory(tenantID, productID, epicID, v, host, sessionToken)
-			for _, v := range v.TestCases {
-				if verbose {
-					fmt.Println("    Creating Test Case: ", v.Name)
-				}
-				testCaseID := createTestCase(tenantID, productID, userStoryID, v, host, sessionToken)
-				for _, v := range v.TestSteps {
-					if verbose {
-						fmt.Println("      Creating Test Step: ", v.Description)
-					}
-					createTestStep(tenantID, productID, testCaseID, v, host, sessionToken)
-				}
-				patchTestCase(tenantID, productID, testCaseID, v, host, sessionToken)
-			}
+func login(ctx context.Context, connection *Connection, verbose bool) (*importer, error) {
+	if connection.Lock != nil && !connection.ReplaceLock {
+		if err := connection.Lock.check(strings.TrimSuffix(connection.Host, "/"), connection.Tenant, connection.ProductID); err != nil {
+			return nil, err
 		}
 	}
-}
-
-func createEpic(tenantID, productID int, epic *Epic, host, token string) (epicID int) {
-	jsonValue, _ := json.Marshal(epic)
-
-	apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID) + "/requirements/epics"
-	request, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewBuffer(jsonValue))
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-		return
+	fmt.Println("Login with: ", connection.User)
+	if connection.TLS != nil && connection.TLS.Insecure {
+		fmt.Fprintln(os.Stderr, "Warning: certificate checks are disabled, the connection to", connection.Host, "is not secure.")
 	}
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Add("Authorization", token)
-	response, err := http.DefaultClient.Do(request)
 
+	options := tbcs.Options{
+		BaseURL:   connection.Host,
+		ProductID: connection.ProductID,
+		TLS:       connection.TLS,
+		Retry:     connection.Retry,
+		RateLimit: connection.RateLimit,
+	}
+	if verbose {
+		options.OnRetry = func(ctx context.Context, retry *tbcs.RetryEvent) { printRetry(output(ctx), retry) }
+		options.OnRelogin = func(ctx context.Context) {
+			fmt.Fprintln(output(ctx), "Session expired, logged in again with: ", connection.User)
+		}
+	}
+	client, err := tbcs.New(options)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-		return
+		return nil, fmt.Errorf("invalid client settings: %w", err)
 	}
-
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 201 {
-		fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
+	if _, err := client.Login(ctx, connection.Tenant, connection.User, connection.Password); err != nil {
+		return nil, &LoginError{Err: err}
 	}
+	return &importer{client: client, verbose: verbose, summary: &Summary{}, out: os.Stdout, workers: connection.Workers, lock: connection.Lock}, nil
+}
 
-	var responseData epicCreatedResponse
-	err = json.Unmarshal(result, &responseData)
-	epicID = responseData.EpicID
-	return
+// logoutTimeout limits the logout, which is also done after the import was canceled.
+const logoutTimeout = 10 * time.Second
+
+// logout closes the session, a failure is only reported.
+func (im *importer) logout() {
+	ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+	defer cancel()
+	if err := im.client.Logout(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Logout failed:", err)
+	} else if im.verbose {
+		fmt.Println("Logged out.")
+	}
+}
 
+func printRetry(w io.Writer, retry *tbcs.RetryEvent) {
+	fmt.Fprintf(w, "Retry %d of %s %s in %v: %v\n", retry.Attempt, retry.Method, retry.Path, retry.Delay.Round(time.Millisecond), retry.Err)
 }
 
-func createUserStory(tenantID, productID, epicID int, userStory *UserStory, host, token string) (userStoryID int) {
-	userStory.EpicID = epicID
-	jsonValue, _ := json.Marshal(userStory)
+type outputKey struct{}
 
-	apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID) + "/requirements/userStories"
-	request, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewBuffer(jsonValue))
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-		return
+// withOutput returns a context whose retries and renewed sessions are
+// printed to w, so they keep their place in the output of a pipeline.
+func withOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// output returns the writer of the context, stdout if it has none.
+func output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
 	}
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Add("Authorization", token)
-	response, err := http.DefaultClient.Do(request)
+	return os.Stdout
+}
 
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
+// queueOutput queues the output buffered so far by the goroutine queueing
+// the work of a pipeline.
+func (im *importer) queueOutput(p *pipeline, buffer *bytes.Buffer) {
+	if buffer.Len() == 0 {
 		return
 	}
+	text := append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	p.report(func() { im.out.Write(text) })
+}
 
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 201 {
-		fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
-	}
+// result returns the summary including the retries of the client.
+func (im *importer) result() *Summary {
+	im.summary.Retries = im.client.Retries()
+	return im.summary
+}
 
-	var responseData userStoryCreatedResponse
-	err = json.Unmarshal(result, &responseData)
-	userStoryID = responseData.UserStoryID
-	return
+// fail reports an element that could not be planned or imported.
+func (im *importer) fail(element string, err error) {
+	fmt.Fprintln(os.Stderr, element+":", err)
+	im.summary.Failures = append(im.summary.Failures, &Failure{Element: element, Err: err})
 }
 
-func createTestCase(tenantID, productID, userStoryID int, testCase *TestCase, host, token string) (testCaseID int) {
-	// check if testcase already exists by external ID, if so update it and return
-	if testCase.TestCaseDetails.ExternalID.Value != "" {
-		//INFO: check in existing test case if there are any changes before (review flag must not be updated then)
-		// https://172.21.3.2/api/tenants/1/products/4/elements?fieldValue=externalId%3Aequals%3ACY-SAMPLE-LOGIN-01&types=TestCase
-		apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID)
-		apiURL += "/elements?fieldValue=externalId%3Aequals%3A" + testCase.TestCaseDetails.ExternalID.Value + "&types=TestCase"
-		request, err := http.NewRequest(http.MethodGet, apiURL, bytes.NewBuffer(make([]byte, 0)))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-			return
-		}
-		request.Header.Set("Content-Type", "application/json; charset=utf-8")
-		request.Header.Add("Authorization", token)
-		response, err := http.DefaultClient.Do(request)
+// skip reports an element that could not be planned or imported together
+// with the given number of test cases depending on it.
+func (im *importer) skip(element string, err error, testCases int) {
+	im.fail(element, err)
+	im.summary.Skipped += testCases
+}
 
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-			return
-		}
+func epicElement(name string) string {
+	return "Epic " + strconv.Quote(name)
+}
 
-		result, _ := ioutil.ReadAll(response.Body)
-		if response.StatusCode != 200 {
-			fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
-		}
+func userStoryElement(name string) string {
+	return "User Story " + strconv.Quote(name)
+}
 
-		var responseData elements
-		err = json.Unmarshal(result, &responseData)
+func testCaseElement(name string) string {
+	return "Test Case " + strconv.Quote(name)
+}
 
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to read response with error ", err)
-			return
-		}
-		if len(responseData.Elements) > 0 && responseData.Elements[0].TestCaseSummary.Tbid != "" {
-			testCaseID = responseData.Elements[0].TestCaseSummary.ID
-			// test case found, now delete all steps of the existing test case, they will be created new
-			deleteAllTestSteps(tenantID, productID, testCaseID, host, token)
+func orphanElement(orphan *OrphanPlan) string {
+	return "Orphaned Test Case " + strconv.Quote(orphan.Name) + " (" + orphan.ExternalID + ")"
+}
 
-			return
-		}
+func countTestCases(userStories ...*UserStory) (count int) {
+	for _, us := range userStories {
+		count += len(us.TestCases)
 	}
+	return
+}
 
-	// create new (not yet existing test case by external id) as usal
-	testCase.UserStroyID = userStoryID
-	testCase.TestCaseType = "StructuredTestCase"
-	jsonValue, _ := json.Marshal(testCase)
+func countPlannedTestCases(userStories ...*UserStoryPlan) (count int) {
+	for _, us := range userStories {
+		count += len(us.TestCases)
+	}
+	return
+}
 
-	apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID) + "/specifications/testCases"
-	request, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewBuffer(jsonValue))
+func (im *importer) applyPlan(ctx context.Context, plan *Plan) {
+	labels, err := im.getLabels(ctx)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-		return
+		im.fail("Labels", err)
 	}
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Add("Authorization", token)
-	response, err := http.DefaultClient.Do(request)
+	if im.lock != nil {
+		im.bindLock(plan.Host, plan.Tenant, plan.ProductID)
+	}
+	var obsoleteUserStoryID int
+	p := newPipeline(im.workers)
+	var queued bytes.Buffer
+	queueCtx := withOutput(ctx, &queued)
+	for _, e := range plan.Epics {
+		e := e
+		if err := ctx.Err(); err != nil {
+			p.report(func() { im.skip(epicElement(e.Name), err, countPlannedTestCases(e.UserStories...)) })
+			continue
+		}
+		epicID := e.ID
+		if e.Action == ActionCreate {
+			im.queueVerbose(p, "Creating Epic: ", e.Name)
+			var err error
+			epicID, err = im.client.CreateEpic(queueCtx, e.Name)
+			im.queueOutput(p, &queued)
+			if err != nil {
+				p.report(func() { im.skip(epicElement(e.Name), err, countPlannedTestCases(e.UserStories...)) })
+				continue
+			}
+		} else {
+			im.queueVerbose(p, "Using existing Epic: ", e.Name)
+		}
+		im.queueLock(p, func(lock *Lock) { lock.setEpic(e.Name, epicID) })
+		for _, us := range e.UserStories {
+			us := us
+			userStoryID := us.ID
+			if us.Action == ActionCreate {
+				im.queueVerbose(p, "  Creating User Story: ", us.Name)
+				var err error
+				userStoryID, err = im.client.CreateUserStory(queueCtx, epicID, us.Name)
+				im.queueOutput(p, &queued)
+				if err != nil {
+					p.report(func() { im.skip(userStoryElement(us.Name), err, len(us.TestCases)) })
+					continue
+				}
+			} else {
+				im.queueVerbose(p, "  Using existing User Story: ", us.Name)
+			}
+			im.queueLock(p, func(lock *Lock) { lock.setUserStory(e.Name, us.Name, userStoryID) })
+			if e.Name == plan.ObsoleteEpic && us.Name == plan.ObsoleteUserStory {
+				obsoleteUserStoryID = userStoryID
+			}
+			for _, tc := range us.TestCases {
+				tc := tc
+				if ctx.Err() != nil {
+					p.report(func() { im.summary.Skipped++ })
+					continue
+				}
+				p.run(func() func() {
+					// the test case is imported by one worker, so its steps keep their order
+					var out bytes.Buffer
+					worker := *im
+					worker.out = &out
+					testCaseID, err := worker.applyTestCase(withOutput(ctx, &out), userStoryID, tc, labels)
+					return func() {
+						im.out.Write(out.Bytes())
+						if err != nil && errors.Is(err, ctx.Err()) {
+							// canceled while importing
+							im.summary.Skipped++
+							return
+						}
+						if err != nil {
+							im.fail(testCaseElement(tc.TestCase.Name), err)
+							im.summary.Failed++
+							return
+						}
+						im.summary.count(tc.Action)
+						if im.lock != nil && tc.TestCase.TestCaseDetails.ExternalID.Value != "" {
+							im.lock.setTestCase(&LockedTestCase{
+								ExternalID:  tc.TestCase.TestCaseDetails.ExternalID.Value,
+								ID:          testCaseID,
+								Name:        tc.TestCase.Name,
+								Epic:        e.Name,
+								UserStory:   us.Name,
+								UserStoryID: userStoryID,
+								Hash:        contentHash(tc.TestCase, tc.Categories),
+							})
+						}
+					}
+				})
+			}
+		}
+	}
+	p.wait()
+	im.applyOrphans(ctx, plan.Orphans, obsoleteUserStoryID)
+	if im.lock != nil {
+		im.lock.prune(plan)
+	}
+}
 
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-		return
+// queueLock queues an update of the lock, if there is one. Updates are
+// reports, so they are done one after another.
+func (im *importer) queueLock(p *pipeline, update func(lock *Lock)) {
+	if im.lock != nil {
+		p.report(func() { update(im.lock) })
 	}
+}
 
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 201 {
-		fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
+// queueVerbose queues a line printed in verbose mode.
+func (im *importer) queueVerbose(p *pipeline, a ...interface{}) {
+	if im.verbose {
+		p.report(func() { fmt.Fprintln(im.out, a...) })
 	}
+}
 
-	var responseData testCaseCreatedResponse
-	err = json.Unmarshal(result, &responseData)
-	testCaseID = responseData.TestCaseID
-	return
+// applyTestCase creates or updates a test case and returns its id, the
+// first failed request ends it. Labels are not assigned if they could not be
+// read or the test case is unchanged according to the lock.
+func (im *importer) applyTestCase(ctx context.Context, userStoryID int, tc *TestCasePlan, labels *labelIDs) (int, error) {
+	v := tc.TestCase
+	testCaseID := tc.ID
+	switch tc.Action {
+	case ActionCreate:
+		if im.verbose {
+			fmt.Fprintln(im.out, "    Creating Test Case: ", v.Name)
+		}
+		var err error
+		if testCaseID, err = im.createTestCase(ctx, userStoryID, v); err != nil {
+			return testCaseID, err
+		}
+		if err := im.patchTestCase(ctx, testCaseID, v, 0); err != nil {
+			return testCaseID, err
+		}
+	case ActionUpdate, ActionMove:
+		if im.verbose {
+			fmt.Fprintln(im.out, "    Updating Test Case: ", v.Name)
+		}
+		if err := im.updateTestSteps(ctx, testCaseID, tc.Steps); err != nil {
+			return testCaseID, err
+		}
+		// the spec moved to another user story, the patch moves the test case too
+		moveTo := 0
+		if tc.Action == ActionMove {
+			moveTo = userStoryID
+		}
+		if err := im.patchTestCase(ctx, testCaseID, v, moveTo); err != nil {
+			return testCaseID, err
+		}
+	default:
+		// nothing to do, the review flag of unchanged test cases must not be touched
+		if im.verbose {
+			fmt.Fprintln(im.out, "    Unchanged Test Case: ", v.Name)
+		}
+	}
+	if len(tc.Categories) > 0 && labels != nil && !tc.Locked {
+		if im.verbose {
+			fmt.Fprintln(im.out, "      Assigning Categories: ", strings.Join(tc.Categories, ", "))
+		}
+		return testCaseID, im.assignLabels(ctx, testCaseID, tc.Action == ActionCreate, tc.Categories, labels)
+	}
+	return testCaseID, nil
 }
 
-func patchTestCase(tenantID, productID, testCaseID int, testCase *TestCase, host, token string) {
-	if len(strings.TrimSpace(testCase.TestCaseDetails.Description.Text)) == 0 {
-		testCase.TestCaseDetails.Description.Text = "TBD"
+// applyOrphans handles the orphaned test cases and lists each of them.
+func (im *importer) applyOrphans(ctx context.Context, orphans []*OrphanPlan, obsoleteUserStoryID int) {
+	automated := false
+	for _, o := range orphans {
+		fmt.Println("Orphaned Test Case: ", o.Name, "("+o.ExternalID+")", "-", orphanActions[o.Action])
+		var err error
+		switch o.Action {
+		case ActionUpdate:
+			err = im.client.PatchTestCase(ctx, o.ID, &tbcs.TestCasePatch{IsAutomated: &automated})
+		case ActionMove:
+			if obsoleteUserStoryID == 0 {
+				err = fmt.Errorf("obsolete user story not available")
+			} else {
+				err = im.client.PatchTestCase(ctx, o.ID, &tbcs.TestCasePatch{UserStoryID: obsoleteUserStoryID})
+			}
+		case ActionDelete:
+			err = im.client.DeleteTestCase(ctx, o.ID)
+		}
+		if err != nil {
+			im.fail(orphanElement(o), err)
+		}
 	}
-	jsonValue, _ := json.Marshal(testCase.TestCaseDetails)
+}
 
-	apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID) + "/specifications/testCases/" + strconv.Itoa(testCaseID)
-	request, err := http.NewRequest(http.MethodPatch, apiURL, bytes.NewBuffer(jsonValue))
+// createTestCase creates a test case with all its test steps.
+func (im *importer) createTestCase(ctx context.Context, userStoryID int, testCase *TestCase) (int, error) {
+	testCaseID, err := im.client.CreateTestCase(ctx, userStoryID, testCase.Name, tbcs.StructuredTestCase)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-		return
+		return 0, err
 	}
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Add("Authorization", token)
-	response, err := http.DefaultClient.Do(request)
+	for _, v := range testCase.TestSteps {
+		if v.TestStepBlock == "" {
+			v.TestStepBlock = TestBlock
+		}
+		if im.verbose {
+			fmt.Fprintln(im.out, "      Creating Test Step: ", v.TestStepBlock, "-", v.Description)
+		}
+		step := &tbcs.TestStepPatch{TestStepBlock: v.TestStepBlock, Description: &v.Description, ExpectedResult: &v.ExpectedResult}
+		if _, err := im.client.CreateTestStep(ctx, testCaseID, step); err != nil {
+			return testCaseID, err
+		}
+	}
+	return testCaseID, nil
+}
 
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-		return
+// patchTestCase sets the details of a test case and moves it to the user
+// story, unless it is 0.
+func (im *importer) patchTestCase(ctx context.Context, testCaseID int, testCase *TestCase, userStoryID int) error {
+	details := testCase.TestCaseDetails
+	patch := &tbcs.TestCasePatch{
+		UserStoryID:  userStoryID,
+		Name:         &details.Name,
+		Description:  &tbcs.Description{Text: effectiveDescription(testCase)},
+		IsAutomated:  &details.IsAutomated,
+		ToBeReviewed: &details.ToBeReviewed,
+		ExternalID:   &tbcs.ExternalID{Value: details.ExternalID.Value},
 	}
+	return im.client.PatchTestCase(ctx, testCaseID, patch)
+}
 
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 200 {
-		if response.StatusCode == 409 {
-			var responseData testCaseUpdateErrorResponse
-			err = json.Unmarshal(result, &responseData)
-			fmt.Fprintln(os.Stderr, response.Status, "-", responseData.Message, "Test case:", testCase.Name)
-		} else {
-			fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
+// updateTestSteps applies the planned edits to the steps of an existing test
+// case. Later edits depend on the positions of the earlier ones, so the
+// first failed edit ends the update.
+func (im *importer) updateTestSteps(ctx context.Context, testCaseID int, changes []*StepChange) error {
+	for _, change := range changes {
+		var err error
+		switch change.Action {
+		case ActionCreate:
+			if im.verbose {
+				fmt.Fprintln(im.out, "      Inserting Test Step: ", change.Block, "-", change.Step.Description)
+			}
+			_, err = im.client.CreateTestStep(ctx, testCaseID, &tbcs.TestStepPatch{
+				TestStepBlock:  change.Block,
+				Description:    &change.Step.Description,
+				ExpectedResult: &change.Step.ExpectedResult,
+				Position:       &change.Position,
+			})
+		case ActionUpdate:
+			if im.verbose {
+				fmt.Fprintln(im.out, "      Updating Test Step: ", change.Block, "-", change.Step.Description)
+			}
+			err = im.client.PatchTestStep(ctx, testCaseID, change.StepID, &tbcs.TestStepPatch{
+				Description:    &change.Step.Description,
+				ExpectedResult: &change.Step.ExpectedResult,
+				Position:       &change.Position,
+			})
+		case ActionMove:
+			if im.verbose {
+				fmt.Fprintln(im.out, "      Moving Test Step: ", change.Block, "-", change.Step.Description)
+			}
+			err = im.client.PatchTestStep(ctx, testCaseID, change.StepID, &tbcs.TestStepPatch{Position: &change.Position})
+		case ActionDelete:
+			if im.verbose {
+				fmt.Fprintln(im.out, "      Deleting Test Step: ", change.Block, "-", change.Step.Description)
+			}
+			err = im.client.DeleteTestStep(ctx, testCaseID, change.StepID)
+		}
+		if err != nil {
+			return err
 		}
 	}
-
-	return
+	return nil
 }
 
-func createTestStep(tenantID, productID, testCaseID int, testStep *TestStep, host, token string) (testStepID int) {
-	testStep.TestStepBlock = "Test"
-	jsonValue, _ := json.Marshal(testStep)
+// labelIDs ids of the labels of the product by name, shared by the workers.
+type labelIDs struct {
+	mutex    sync.Mutex // guards ids
+	ids      map[string]int
+	creating sync.Mutex // serializes the creation of missing labels
+}
 
-	apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID) + "/specifications/testCases/" + strconv.Itoa(testCaseID) + "/testSteps"
-	request, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewBuffer(jsonValue))
+// getLabels returns the ids of all labels of the product.
+func (im *importer) getLabels(ctx context.Context) (*labelIDs, error) {
+	found, err := im.client.GetLabels(ctx)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-		return
+		return nil, err
+	}
+	labels := &labelIDs{ids: map[string]int{}}
+	for _, l := range found {
+		labels.ids[l.Name] = l.ID
 	}
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Add("Authorization", token)
-	response, err := http.DefaultClient.Do(request)
+	return labels, nil
+}
+
+func (labels *labelIDs) get(name string) (int, bool) {
+	labels.mutex.Lock()
+	defer labels.mutex.Unlock()
+	id, found := labels.ids[name]
+	return id, found
+}
 
+// labelID returns the id of the label with the given name. A missing label
+// is created unless another worker did so in the meantime.
+func (im *importer) labelID(ctx context.Context, labels *labelIDs, name string) (int, error) {
+	if id, found := labels.get(name); found {
+		return id, nil
+	}
+	labels.creating.Lock()
+	defer labels.creating.Unlock()
+	if id, found := labels.get(name); found {
+		return id, nil
+	}
+	id, err := im.client.CreateLabel(ctx, name)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-		return
+		return 0, err
 	}
+	labels.mutex.Lock()
+	labels.ids[name] = id
+	labels.mutex.Unlock()
+	return id, nil
+}
 
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 201 {
-		fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
+// assignLabels adds the labels of its categories to a test case, labels
+// assigned in TestBench CS are kept. Missing labels are created. The labels
+// of a created test case are not read, it has none yet.
+func (im *importer) assignLabels(ctx context.Context, testCaseID int, created bool, categories []string, labels *labelIDs) error {
+	var assigned []int
+	if !created {
+		existing, err := im.client.GetTestCase(ctx, testCaseID)
+		if err != nil {
+			return err
+		}
+		assigned = existing.Labels
+	}
+	labelIDs := append([]int{}, assigned...)
+	for _, category := range categories {
+		labelID, err := im.labelID(ctx, labels, category)
+		if err != nil {
+			return err
+		}
+		if !containsID(labelIDs, labelID) {
+			labelIDs = append(labelIDs, labelID)
+		}
 	}
+	if len(labelIDs) == len(assigned) {
+		return nil
+	}
+	return im.client.SetTestCaseLabels(ctx, testCaseID, labelIDs)
+}
 
-	var responseData testStepCreatedResponse
-	err = json.Unmarshal(result, &responseData)
-	testCaseID = responseData.TestStepID
-	return
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
 }
 
-func deleteAllTestSteps(tenantID, productID, testCaseID int, host, token string) {
-	apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID) + "/specifications/testCases/" + strconv.Itoa(testCaseID)
-	//request, err := http.NewRequest("DELETE", apiURL, bytes.NewBuffer(make([]byte, 0)))
-	request, err := http.NewRequest(http.MethodGet, apiURL, bytes.NewBuffer(make([]byte, 0)))
+// findEpic returns the id of the epic with the given name, 0 if there is none.
+func (im *importer) findEpic(ctx context.Context, name string) (int, error) {
+	epics, err := im.client.SearchEpics(ctx, "name", name)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-		return
+		return 0, err
 	}
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Add("Authorization", token)
-	response, err := http.DefaultClient.Do(request)
+	for _, e := range epics {
+		if e.Name == name {
+			return e.ID, nil
+		}
+	}
+	return 0, nil
+}
 
+// findUserStory returns the id of the user story with the given name within
+// the epic, 0 if there is none.
+func (im *importer) findUserStory(ctx context.Context, epicID int, name string) (int, error) {
+	if epicID == 0 {
+		return 0, nil
+	}
+	userStories, err := im.client.SearchUserStories(ctx, "name", name)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-		return
+		return 0, err
 	}
-
-	result, _ := ioutil.ReadAll(response.Body)
-	if response.StatusCode != 200 {
-		fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
+	for _, us := range userStories {
+		if us.Name == name && us.EpicID == epicID {
+			return us.ID, nil
+		}
 	}
+	return 0, nil
+}
 
-	var responseData getTestCaseResponse
-	err = json.Unmarshal(result, &responseData)
-
-	// delete each step in test step block named "Test"
-	for _, block := range responseData.TestSequence.TestStepBlocks {
-		if block.Name == "Test" {
-			for _, step := range block.Steps {
-				apiURL := host + "/api/tenants/" + strconv.Itoa(tenantID) + "/products/" + strconv.Itoa(productID)
-				apiURL += "/specifications/testCases/" + strconv.Itoa(testCaseID) + "/testSteps/" + strconv.Itoa(step.ID)
-				request, err := http.NewRequest("DELETE", apiURL, bytes.NewBuffer(make([]byte, 0)))
-				if err != nil {
-					fmt.Fprintln(os.Stderr, "The HTTP request creation failed with error ", err)
-					return
-				}
-				request.Header.Set("Content-Type", "application/json; charset=utf-8")
-				request.Header.Add("Authorization", token)
-				response, err := http.DefaultClient.Do(request)
-
-				if err != nil {
-					fmt.Fprintln(os.Stderr, "The HTTP request failed with error ", err)
-					return
-				}
-
-				result, _ := ioutil.ReadAll(response.Body)
-				if response.StatusCode != 200 {
-					fmt.Fprintln(os.Stderr, "Request failed with: ", response.Status, " Response: ", string(result))
-				}
-			}
-		}
+// findTestCase returns the test case with the external id of the parsed one,
+// nil if it has none or there is no such test case yet.
+func (im *importer) findTestCase(ctx context.Context, testCase *TestCase) (*tbcs.TestCase, error) {
+	if testCase.TestCaseDetails.ExternalID.Value == "" {
+		return nil, nil
 	}
-	return
+	found, err := im.client.SearchTestCases(ctx, "externalId", testCase.TestCaseDetails.ExternalID.Value)
+	if err != nil || len(found) == 0 || found[0].Tbid == "" {
+		return nil, err
+	}
+	return im.client.GetTestCase(ctx, found[0].ID)
 }
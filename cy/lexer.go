@@ -0,0 +1,379 @@
+package cy
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenTemplate
+	tokenNumber
+	tokenRegexp
+	tokenPunct
+)
+
+// token single lexical element of a JavaScript/TypeScript source.
+type token struct {
+	kind   tokenKind
+	text   string // raw source text
+	value  string // decoded value of string and template tokens
+	embeds bool   // template with embedded ${...} expressions, kept verbatim in value
+	offset int    // rune offset into the source
+	end    int    // rune offset behind the token
+	line   int
+	column int
+}
+
+// syntaxError problem found while tokenizing or parsing a source.
+type syntaxError struct {
+	line    int
+	column  int
+	message string
+}
+
+// lexer tokenizes JavaScript/TypeScript sources. It knows enough of the
+// language to skip comments, strings, template literals and regular
+// expressions correctly, everything else is handed out as identifiers,
+// numbers and punctuation.
+type lexer struct {
+	src    []rune
+	pos    int
+	line   int
+	column int
+	last   *token
+	errs   []syntaxError
+}
+
+// keywords after which a slash starts a regular expression instead of a division.
+var regexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// multi character punctuators the parser cares about, longest first.
+var punctuators = []string{"...", "=>", "?."}
+
+func tokenize(src string) (tokens []token, errs []syntaxError) {
+	l := &lexer{src: []rune(src), line: 1, column: 1}
+	for {
+		t := l.nextToken()
+		tokens = append(tokens, t)
+		if t.kind == tokenEOF {
+			break
+		}
+	}
+	return tokens, l.errs
+}
+
+func (l *lexer) peekRune(ahead int) rune {
+	if l.pos+ahead >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+ahead]
+}
+
+func (l *lexer) hasPrefix(s string) bool {
+	for i, r := range []rune(s) {
+		if l.peekRune(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.pos]
+	l.pos++
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+func (l *lexer) errorf(line, column int, message string) {
+	l.errs = append(l.errs, syntaxError{line: line, column: column, message: message})
+}
+
+func (l *lexer) nextToken() token {
+	l.skipSpaceAndComments()
+
+	t := token{offset: l.pos, line: l.line, column: l.column}
+	if l.pos >= len(l.src) {
+		t.kind = tokenEOF
+		t.end = l.pos
+		return t
+	}
+
+	r := l.peekRune(0)
+	switch {
+	case isIdentStart(r):
+		for l.pos < len(l.src) && isIdentPart(l.peekRune(0)) {
+			l.advance()
+		}
+		t.kind = tokenIdent
+	case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(l.peekRune(1))):
+		for l.pos < len(l.src) && (isIdentPart(l.peekRune(0)) || l.peekRune(0) == '.') {
+			l.advance()
+		}
+		t.kind = tokenNumber
+	case r == '\'' || r == '"':
+		t.kind = tokenString
+		t.value = l.scanString(r)
+	case r == '`':
+		t.kind = tokenTemplate
+		t.value, t.embeds = l.scanTemplate()
+	case r == '/' && l.regexpAllowed():
+		t.kind = tokenRegexp
+		l.scanRegexp()
+	default:
+		t.kind = tokenPunct
+		matched := false
+		for _, p := range punctuators {
+			if l.hasPrefix(p) {
+				for range p {
+					l.advance()
+				}
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			l.advance()
+		}
+	}
+
+	t.end = l.pos
+	t.text = string(l.src[t.offset:t.end])
+	l.last = &t
+	return t
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.src) {
+		r := l.peekRune(0)
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '/' && l.peekRune(1) == '/':
+			for l.pos < len(l.src) && l.peekRune(0) != '\n' {
+				l.advance()
+			}
+		case r == '/' && l.peekRune(1) == '*':
+			line, column := l.line, l.column
+			l.advance()
+			l.advance()
+			for {
+				if l.pos >= len(l.src) {
+					l.errorf(line, column, "unterminated comment")
+					return
+				}
+				if l.peekRune(0) == '*' && l.peekRune(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// regexpAllowed decides whether a slash at the current position starts a
+// regular expression literal, based on the previous token.
+func (l *lexer) regexpAllowed() bool {
+	if l.peekRune(1) == '/' || l.peekRune(1) == '*' {
+		return false
+	}
+	if l.last == nil {
+		return true
+	}
+	switch l.last.kind {
+	case tokenIdent:
+		return regexpKeywords[l.last.text]
+	case tokenNumber, tokenString, tokenTemplate, tokenRegexp:
+		return false
+	case tokenPunct:
+		return l.last.text != ")" && l.last.text != "]" && l.last.text != "}"
+	}
+	return true
+}
+
+func (l *lexer) scanString(quote rune) string {
+	line, column := l.line, l.column
+	l.advance()
+	var value strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.peekRune(0) == '\n' {
+			l.errorf(line, column, "unterminated string")
+			return value.String()
+		}
+		r := l.advance()
+		switch r {
+		case quote:
+			return value.String()
+		case '\\':
+			l.scanEscape(&value)
+		default:
+			value.WriteRune(r)
+		}
+	}
+}
+
+// scanTemplate reads a template literal. Embedded expressions are kept
+// verbatim in the value, e.g. `user ${name}` results in "user ${name}",
+// embeds reports whether there were any.
+func (l *lexer) scanTemplate() (value string, embeds bool) {
+	line, column := l.line, l.column
+	l.advance()
+	var text strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			l.errorf(line, column, "unterminated template literal")
+			return text.String(), embeds
+		}
+		r := l.advance()
+		switch {
+		case r == '`':
+			return text.String(), embeds
+		case r == '\\':
+			l.scanEscape(&text)
+		case r == '$' && l.peekRune(0) == '{':
+			start := l.pos - 1
+			l.skipTemplateExpression()
+			text.WriteString(string(l.src[start:l.pos]))
+			embeds = true
+		default:
+			text.WriteRune(r)
+		}
+	}
+}
+
+// skipTemplateExpression skips a ${...} expression including nested braces,
+// strings and templates.
+func (l *lexer) skipTemplateExpression() {
+	line, column := l.line, l.column
+	l.advance()
+	depth := 1
+	for depth > 0 {
+		if l.pos >= len(l.src) {
+			l.errorf(line, column, "unterminated template expression")
+			return
+		}
+		switch l.peekRune(0) {
+		case '{':
+			depth++
+			l.advance()
+		case '}':
+			depth--
+			l.advance()
+		case '\'', '"':
+			l.scanString(l.peekRune(0))
+		case '`':
+			l.scanTemplate()
+		default:
+			l.advance()
+		}
+	}
+}
+
+func (l *lexer) scanEscape(value *strings.Builder) {
+	if l.pos >= len(l.src) {
+		return
+	}
+	r := l.advance()
+	switch r {
+	case 'n':
+		value.WriteRune('\n')
+	case 't':
+		value.WriteRune('\t')
+	case 'r':
+		value.WriteRune('\r')
+	case 'b':
+		value.WriteRune('\b')
+	case 'f':
+		value.WriteRune('\f')
+	case 'v':
+		value.WriteRune('\v')
+	case '0':
+		value.WriteRune(0)
+	case '\n':
+		// line continuation
+	case 'x':
+		value.WriteRune(l.scanHex(2))
+	case 'u':
+		if l.peekRune(0) == '{' {
+			l.advance()
+			var digits strings.Builder
+			for l.pos < len(l.src) && l.peekRune(0) != '}' {
+				digits.WriteRune(l.advance())
+			}
+			if l.pos < len(l.src) {
+				l.advance()
+			}
+			code, _ := strconv.ParseUint(digits.String(), 16, 32)
+			value.WriteRune(rune(code))
+		} else {
+			value.WriteRune(l.scanHex(4))
+		}
+	default:
+		value.WriteRune(r)
+	}
+}
+
+func (l *lexer) scanHex(digits int) rune {
+	start := l.pos
+	for i := 0; i < digits && l.pos < len(l.src); i++ {
+		l.advance()
+	}
+	code, err := strconv.ParseUint(string(l.src[start:l.pos]), 16, 32)
+	if err != nil {
+		return unicode.ReplacementChar
+	}
+	return rune(code)
+}
+
+func (l *lexer) scanRegexp() {
+	line, column := l.line, l.column
+	l.advance()
+	inClass := false
+	for {
+		if l.pos >= len(l.src) || l.peekRune(0) == '\n' {
+			l.errorf(line, column, "unterminated regular expression")
+			return
+		}
+		r := l.advance()
+		switch {
+		case r == '\\' && l.pos < len(l.src):
+			l.advance()
+		case r == '[':
+			inClass = true
+		case r == ']':
+			inClass = false
+		case r == '/' && !inClass:
+			for l.pos < len(l.src) && isIdentPart(l.peekRune(0)) {
+				l.advance()
+			}
+			return
+		}
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,124 @@
+package cy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeStrings(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		kind   tokenKind
+		value  string
+		embeds bool
+	}{
+		{"single quotes", `'it works'`, tokenString, "it works", false},
+		{"double quotes", `"it works"`, tokenString, "it works", false},
+		{"other quote inside", `"it's"`, tokenString, "it's", false},
+		{"escaped quote", `'it\'s'`, tokenString, "it's", false},
+		{"escapes", `"a\tb\nc\\d"`, tokenString, "a\tb\nc\\d", false},
+		{"hex and unicode escapes", `'\x41B\u{43}'`, tokenString, "ABC", false},
+		{"line continuation", "'a\\\nb'", tokenString, "ab", false},
+		{"template", "`it works`", tokenTemplate, "it works", false},
+		{"template over lines", "`a\nb`", tokenTemplate, "a\nb", false},
+		{"template escape", "`a\\`b`", tokenTemplate, "a`b", false},
+		{"template escaped expression", "`a \\${b}`", tokenTemplate, "a ${b}", false},
+		{"template expression", "`user ${name}`", tokenTemplate, "user ${name}", true},
+		{"nested template expression", "`a ${f({b: `c ${d}`})} e`", tokenTemplate, "a ${f({b: `c ${d}`})} e", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, errs := tokenize(tt.src)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if len(tokens) != 2 {
+				t.Fatalf("got %d tokens, want 1 and EOF", len(tokens)-1)
+			}
+			tok := tokens[0]
+			if tok.kind != tt.kind || tok.value != tt.value || tok.embeds != tt.embeds {
+				t.Errorf("got kind %d value %q embeds %t, want kind %d value %q embeds %t",
+					tok.kind, tok.value, tok.embeds, tt.kind, tt.value, tt.embeds)
+			}
+			if tok.text != tt.src {
+				t.Errorf("got text %q, want %q", tok.text, tt.src)
+			}
+		})
+	}
+}
+
+func TestTokenizeRegexp(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		kinds []tokenKind
+	}{
+		{"at start", `/a/g`, []tokenKind{tokenRegexp}},
+		{"argument", `f(/a\/b/)`, []tokenKind{tokenIdent, tokenPunct, tokenRegexp, tokenPunct}},
+		{"slash in class", `x = /[/]/`, []tokenKind{tokenIdent, tokenPunct, tokenRegexp}},
+		{"after keyword", `return /a/`, []tokenKind{tokenIdent, tokenRegexp}},
+		{"division of identifiers", `a / b / c`, []tokenKind{tokenIdent, tokenPunct, tokenIdent, tokenPunct, tokenIdent}},
+		{"division of numbers", `4 / 2`, []tokenKind{tokenNumber, tokenPunct, tokenNumber}},
+		{"division after parenthesis", `(a) / 2`, []tokenKind{tokenPunct, tokenIdent, tokenPunct, tokenPunct, tokenNumber}},
+		{"division after bracket", `a[0] / 2`, []tokenKind{tokenIdent, tokenPunct, tokenNumber, tokenPunct, tokenPunct, tokenNumber}},
+		{"comments are no regexp", "a // b\n/* c */ d", []tokenKind{tokenIdent, tokenIdent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, errs := tokenize(tt.src)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			var kinds []tokenKind
+			for _, tok := range tokens[:len(tokens)-1] {
+				kinds = append(kinds, tok.kind)
+			}
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Errorf("got kinds %v, want %v", kinds, tt.kinds)
+			}
+		})
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	tokens, _ := tokenize("a\n  'b'\n/* c\n */ `d\ne` f")
+	want := []struct {
+		text         string
+		line, column int
+	}{
+		{"a", 1, 1},
+		{"'b'", 2, 3},
+		{"`d\ne`", 4, 5},
+		{"f", 5, 4},
+	}
+	for i, w := range want {
+		tok := tokens[i]
+		if tok.text != w.text || tok.line != w.line || tok.column != w.column {
+			t.Errorf("token %d: got %q at %d:%d, want %q at %d:%d", i, tok.text, tok.line, tok.column, w.text, w.line, w.column)
+		}
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want syntaxError
+	}{
+		{"string", `it('title`, syntaxError{1, 4, "unterminated string"}},
+		{"string at line end", "it(\"title\n)", syntaxError{1, 4, "unterminated string"}},
+		{"comment", "a /* b\nc", syntaxError{1, 3, "unterminated comment"}},
+		{"template", "x = `a\nb", syntaxError{1, 5, "unterminated template literal"}},
+		{"template expression", "x = `a ${b", syntaxError{1, 9, "unterminated template expression"}},
+		{"regexp", "x = /a\n/", syntaxError{1, 5, "unterminated regular expression"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := tokenize(tt.src)
+			if len(errs) == 0 || errs[0] != tt.want {
+				t.Errorf("got errors %v, want %v", errs, tt.want)
+			}
+		})
+	}
+}
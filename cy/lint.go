@@ -0,0 +1,215 @@
+package cy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SeverityOff disables a lint rule.
+const SeverityOff Severity = "off"
+
+// Lint rule names.
+const (
+	RuleMissingAutID   = "missing-autid"
+	RuleDuplicateAutID = "duplicate-autid"
+	RuleNoSteps        = "no-steps"
+	RuleEmptyName      = "empty-name"
+	RuleDuplicateName  = "duplicate-name"
+	RuleOnly           = "only"
+	RuleMetaAfterStep  = "meta-after-step"
+	RuleTitleLength    = "title-length"
+)
+
+// DefaultMaxTitleLength maximum length of element names accepted by TestBench CS.
+const DefaultMaxTitleLength = 255
+
+// LintRule check of the spec files against the TestBench CS import rules.
+type LintRule struct {
+	Name        string
+	Description string
+	Severity    Severity // default severity
+}
+
+// LintRules lists all lint rules with their default severity.
+var LintRules = []*LintRule{
+	{RuleMissingAutID, "test without TBCS_AUTID, it can not be updated on re-import", SeverityWarning},
+	{RuleDuplicateAutID, "TBCS_AUTID used by several tests", SeverityError},
+	{RuleNoSteps, "test without cy.log steps", SeverityWarning},
+	{RuleEmptyName, "describe or test with empty title", SeverityError},
+	{RuleDuplicateName, "several tests with the same name in a user story", SeverityError},
+	{RuleOnly, ".only left in the code", SeverityError},
+	{RuleMetaAfterStep, "meta call placed after the first step", SeverityWarning},
+	{RuleTitleLength, "name longer than the TestBench CS limit", SeverityError},
+}
+
+func lintRule(name string) *LintRule {
+	for _, r := range LintRules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// LintConfig severities of the lint rules by rule name. It implements
+// flag.Value, so rules can be configured like "no-steps=error,only=off".
+type LintConfig map[string]Severity
+
+// DefaultLintConfig returns a configuration with the default severity of all rules.
+func DefaultLintConfig() LintConfig {
+	config := LintConfig{}
+	for _, r := range LintRules {
+		config[r.Name] = r.Severity
+	}
+	return config
+}
+
+// String returns the configuration in the format accepted by Set.
+func (c LintConfig) String() string {
+	var rules []string
+	for name, severity := range c {
+		rules = append(rules, name+"="+string(severity))
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ",")
+}
+
+// Set changes the severity of the given comma separated rules.
+func (c LintConfig) Set(value string) error {
+	for _, rule := range strings.Split(value, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if lintRule(name) == nil {
+			return fmt.Errorf("unknown lint rule %q", name)
+		}
+		if len(parts) != 2 {
+			return fmt.Errorf("missing severity for lint rule %q", name)
+		}
+		severity := Severity(strings.TrimSpace(parts[1]))
+		if severity != SeverityError && severity != SeverityWarning && severity != SeverityOff {
+			return fmt.Errorf("invalid severity %q for lint rule %q, valid values are: error, warning, off", severity, name)
+		}
+		c[name] = severity
+	}
+	return nil
+}
+
+// Lint checks the parsed elements against the lint rules. The diagnostics
+// of ParseSpecs are included, the severity of rule violations found while
+// parsing is adjusted to the configuration.
+func Lint(epics []*Epic, diagnostics []Diagnostic, config LintConfig, maxTitleLength int) []Diagnostic {
+	l := &linter{config: config}
+	for _, d := range diagnostics {
+		l.add(d)
+	}
+
+	autIDs := map[string]*TestCase{}
+	for _, e := range epics {
+		l.checkTitleLength(firstTestCase(e.UserStories...), "epic", e.Name, maxTitleLength)
+		for _, us := range e.UserStories {
+			l.checkTitleLength(firstTestCase(us), "user story", us.Name, maxTitleLength)
+			names := map[string]*TestCase{}
+			for _, tc := range us.TestCases {
+				if other, found := names[tc.Name]; found {
+					l.violate(tc, RuleDuplicateName, fmt.Sprintf("test case %q is already defined at %s:%d", tc.Name, other.File, other.Line))
+				} else {
+					names[tc.Name] = tc
+				}
+				l.checkTitleLength(tc, "test case", tc.Name, maxTitleLength)
+				l.lintTestCase(tc, autIDs)
+			}
+		}
+	}
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.diagnostics
+}
+
+type linter struct {
+	config      LintConfig
+	diagnostics []Diagnostic
+}
+
+// add keeps a diagnostic, rule violations get the configured severity.
+func (l *linter) add(d Diagnostic) {
+	if d.Rule != "" {
+		if severity, found := l.config[d.Rule]; found {
+			d.Severity = severity
+		}
+		if d.Severity == SeverityOff {
+			return
+		}
+	}
+	l.diagnostics = append(l.diagnostics, d)
+}
+
+func (l *linter) violate(tc *TestCase, rule, message string) {
+	l.add(Diagnostic{
+		File:     tc.File,
+		Line:     tc.Line,
+		Column:   tc.Column,
+		Severity: lintRule(rule).Severity,
+		Rule:     rule,
+		Message:  message,
+	})
+}
+
+// checkTitleLength reports a name longer than the limit at the given test
+// case, epics and user stories are reported at their first one. Elements
+// without test cases are reported without position.
+func (l *linter) checkTitleLength(at *TestCase, element, name string, maxTitleLength int) {
+	if utf8.RuneCountInString(name) <= maxTitleLength {
+		return
+	}
+	message := fmt.Sprintf("%s name %q is longer than %d characters", element, name, maxTitleLength)
+	if at == nil {
+		l.add(Diagnostic{Severity: lintRule(RuleTitleLength).Severity, Rule: RuleTitleLength, Message: message})
+		return
+	}
+	l.violate(at, RuleTitleLength, message)
+}
+
+// firstTestCase returns the first test case of the user stories, nil if they have none.
+func firstTestCase(userStories ...*UserStory) *TestCase {
+	for _, us := range userStories {
+		if len(us.TestCases) > 0 {
+			return us.TestCases[0]
+		}
+	}
+	return nil
+}
+
+func (l *linter) lintTestCase(tc *TestCase, autIDs map[string]*TestCase) {
+	autID := tc.TestCaseDetails.ExternalID.Value
+	if autID == "" {
+		l.violate(tc, RuleMissingAutID, fmt.Sprintf("test %q has no TBCS_AUTID", tc.Title))
+	} else if other, found := autIDs[autID]; found {
+		l.violate(tc, RuleDuplicateAutID, fmt.Sprintf("TBCS_AUTID %q is already used at %s:%d", autID, other.File, other.Line))
+	} else {
+		autIDs[autID] = tc
+	}
+
+	steps := 0
+	for _, ts := range tc.TestSteps {
+		if ts.TestStepBlock == TestBlock {
+			steps++
+		}
+	}
+	if steps == 0 && !tc.Skipped {
+		l.violate(tc, RuleNoSteps, fmt.Sprintf("test %q has no cy.log steps", tc.Title))
+	}
+}
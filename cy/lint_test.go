@@ -0,0 +1,43 @@
+package cy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintTitleLength(t *testing.T) {
+	testCase := func(name string, line int) *TestCase {
+		return &TestCase{
+			Name:            name,
+			Title:           name,
+			TestSteps:       []*TestStep{{TestStepBlock: TestBlock, Description: "step"}},
+			TestCaseDetails: &TestCasePatch{Name: name, ExternalID: &ExternalID{Value: name}},
+			File:            "a.cy.js",
+			Line:            line,
+			Column:          3,
+		}
+	}
+	epics := []*Epic{
+		{Name: "Long epic", UserStories: []*UserStory{
+			{Name: "Empty story"},
+			{Name: "Story", TestCases: []*TestCase{testCase("Short", 1), testCase("Long test", 2)}},
+			{Name: "Long story", TestCases: []*TestCase{testCase("Test", 3)}},
+			{Name: "Long empty"},
+		}},
+		{Name: "Epic"},
+	}
+	var got []string
+	for _, d := range Lint(epics, nil, DefaultLintConfig(), 8) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`error: user story name "Empty story" is longer than 8 characters [title-length]`,
+		`error: user story name "Long empty" is longer than 8 characters [title-length]`,
+		`a.cy.js:1:3: error: epic name "Long epic" is longer than 8 characters [title-length]`,
+		`a.cy.js:2:3: error: test case name "Long test" is longer than 8 characters [title-length]`,
+		`a.cy.js:3:3: error: user story name "Long story" is longer than 8 characters [title-length]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,366 @@
+package cy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// DefaultLockFile lock file used if none is given.
+const DefaultLockFile = ".tbcs-lock.json"
+
+// LockVersion version of the lock file format.
+const LockVersion = 1
+
+// Lock ids of the epics, user stories and test cases imported into one
+// product, kept in a lock file committed together with the specs. Test cases
+// are identified by their TBCS_AUTID and locked with the hash of the content
+// imported last. A test case with the same hash in the same user story is
+// not compared with TestBench CS again. The lock is verified against the
+// product before planning, entries of deleted or renamed elements are stale
+// and looked up again.
+type Lock struct {
+	Version   int               `json:"version"`
+	Host      string            `json:"host"`
+	Tenant    string            `json:"tenant"`
+	ProductID int               `json:"productId"`
+	Epics     []*LockedEpic     `json:"epics"`
+	TestCases []*LockedTestCase `json:"testCases"`
+}
+
+// LockedEpic id of an epic and its user stories.
+type LockedEpic struct {
+	Name        string             `json:"name"`
+	ID          int                `json:"id"`
+	UserStories []*LockedUserStory `json:"userStories"`
+}
+
+// LockedUserStory id of a user story.
+type LockedUserStory struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+// LockedTestCase id and location of a test case and the hash of its content
+// imported last.
+type LockedTestCase struct {
+	ExternalID  string `json:"externalId"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Epic        string `json:"epic"`
+	UserStory   string `json:"userStory"`
+	UserStoryID int    `json:"userStoryId"`
+	Hash        string `json:"hash"`
+}
+
+// LoadLock reads a lock file written by SaveLock, an empty lock if the file
+// does not exist yet.
+func LoadLock(file string) (*Lock, error) {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &Lock{Version: LockVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock Lock
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if lock.Version != LockVersion {
+		return nil, fmt.Errorf("%s: unsupported lock version %d, expected %d", file, lock.Version, LockVersion)
+	}
+	return &lock, nil
+}
+
+// SaveLock writes the lock as JSON file, sorted so that changes are easy to
+// review.
+func SaveLock(file string, lock *Lock) error {
+	sort.Slice(lock.Epics, func(i, j int) bool { return lock.Epics[i].Name < lock.Epics[j].Name })
+	for _, e := range lock.Epics {
+		sort.Slice(e.UserStories, func(i, j int) bool { return e.UserStories[i].Name < e.UserStories[j].Name })
+	}
+	sort.Slice(lock.TestCases, func(i, j int) bool { return lock.TestCases[i].ExternalID < lock.TestCases[j].ExternalID })
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(content, '\n'), 0644)
+}
+
+// LockError the lock file belongs to another host, workspace or product than
+// the import. Such a lock is only replaced with Connection.ReplaceLock.
+type LockError struct {
+	Host      string
+	Tenant    string
+	ProductID int
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("lock file is for product %d of workspace %s at %s", e.ProductID, e.Tenant, e.Host)
+}
+
+// isFor reports whether the lock belongs to the product, an empty lock
+// belongs to any.
+func (l *Lock) isFor(host, tenant string, productID int) bool {
+	return (l.Host == host && l.Tenant == tenant && l.ProductID == productID) ||
+		(len(l.Epics) == 0 && len(l.TestCases) == 0)
+}
+
+// check returns a *LockError if the lock belongs to another product.
+func (l *Lock) check(host, tenant string, productID int) error {
+	if l.isFor(host, tenant, productID) {
+		return nil
+	}
+	return &LockError{Host: l.Host, Tenant: l.Tenant, ProductID: l.ProductID}
+}
+
+// bind makes the lock one of the product. A lock of another product is
+// emptied, false is returned then.
+func (l *Lock) bind(host, tenant string, productID int) bool {
+	if l.Host == host && l.Tenant == tenant && l.ProductID == productID {
+		return true
+	}
+	replaced := !l.isFor(host, tenant, productID)
+	*l = Lock{Version: LockVersion, Host: host, Tenant: tenant, ProductID: productID}
+	return !replaced
+}
+
+func (l *Lock) epic(name string) *LockedEpic {
+	for _, e := range l.Epics {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (l *Lock) setEpic(name string, id int) {
+	if e := l.epic(name); e != nil {
+		if e.ID != id {
+			e.ID, e.UserStories = id, nil
+		}
+		return
+	}
+	l.Epics = append(l.Epics, &LockedEpic{Name: name, ID: id})
+}
+
+func (l *Lock) setUserStory(epicName, name string, id int) {
+	e := l.epic(epicName)
+	if e == nil {
+		return
+	}
+	for _, us := range e.UserStories {
+		if us.Name == name {
+			us.ID = id
+			return
+		}
+	}
+	e.UserStories = append(e.UserStories, &LockedUserStory{Name: name, ID: id})
+}
+
+func (l *Lock) setTestCase(testCase *LockedTestCase) {
+	for i, tc := range l.TestCases {
+		if tc.ExternalID == testCase.ExternalID {
+			l.TestCases[i] = testCase
+			return
+		}
+	}
+	l.TestCases = append(l.TestCases, testCase)
+}
+
+// prune removes the entries of elements not part of the plan, test cases of
+// omitted tests are kept.
+func (l *Lock) prune(plan *Plan) {
+	userStories := map[string]map[string]bool{}
+	autIDs := map[string]bool{}
+	for _, e := range plan.Epics {
+		userStories[e.Name] = map[string]bool{}
+		for _, us := range e.UserStories {
+			userStories[e.Name][us.Name] = true
+			for _, tc := range us.TestCases {
+				autIDs[tc.TestCase.TestCaseDetails.ExternalID.Value] = true
+			}
+		}
+	}
+	for _, autID := range plan.Omitted {
+		autIDs[autID] = true
+	}
+	epics := l.Epics[:0]
+	for _, e := range l.Epics {
+		if userStories[e.Name] == nil {
+			continue
+		}
+		kept := e.UserStories[:0]
+		for _, us := range e.UserStories {
+			if userStories[e.Name][us.Name] {
+				kept = append(kept, us)
+			}
+		}
+		e.UserStories = kept
+		epics = append(epics, e)
+	}
+	l.Epics = epics
+	testCases := l.TestCases[:0]
+	for _, tc := range l.TestCases {
+		if autIDs[tc.ExternalID] {
+			testCases = append(testCases, tc)
+		}
+	}
+	l.TestCases = testCases
+}
+
+// contentHash returns the hash of the imported content of a test case.
+func contentHash(testCase *TestCase, categories []string) string {
+	steps := make([]TestStep, len(testCase.TestSteps))
+	for i, ts := range testCase.TestSteps {
+		steps[i] = *ts
+		if steps[i].TestStepBlock == "" {
+			steps[i].TestStepBlock = TestBlock
+		}
+	}
+	content, _ := json.Marshal(struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		IsAutomated bool       `json:"isAutomated"`
+		Steps       []TestStep `json:"steps"`
+		Categories  []string   `json:"categories"`
+	}{testCase.TestCaseDetails.Name, effectiveDescription(testCase), testCase.TestCaseDetails.IsAutomated, steps, categories})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// lockIndex verified entries of the lock completed by the elements of the
+// product, made once before planning and only read while planning.
+type lockIndex struct {
+	epics       map[string]int
+	userStories map[userStoryKey]int
+	testCases   map[string]*LockedTestCase // verified entries by external id
+	found       map[string]int             // ids of all test cases by external id
+	stale       int
+}
+
+type userStoryKey struct {
+	epicID int
+	name   string
+}
+
+// bindLock makes the lock one of the product, a lock of another product is
+// replaced. Login refused such a lock unless Connection.ReplaceLock is set.
+func (im *importer) bindLock(host, tenant string, productID int) {
+	if !im.lock.bind(host, tenant, productID) {
+		fmt.Fprintf(im.out, "Lock file is not for product %d of workspace %s at %s, it is replaced.\n", productID, tenant, host)
+	}
+}
+
+// verifyLock reads all epics, user stories and test cases of the product
+// and checks the entries of the lock against them. Entries of elements that
+// no longer exist or have another name or external id are stale, those
+// elements are looked up by name or external id instead. This replaces the
+// searches for single elements.
+func (im *importer) verifyLock(ctx context.Context) (*lockIndex, error) {
+	epics, err := im.client.SearchEpics(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	userStories, err := im.client.SearchUserStories(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	testCases, err := im.client.SearchTestCases(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	index := &lockIndex{
+		epics:       map[string]int{},
+		userStories: map[userStoryKey]int{},
+		testCases:   map[string]*LockedTestCase{},
+		found:       map[string]int{},
+	}
+	epicNames := map[int]string{}
+	for _, e := range epics {
+		epicNames[e.ID] = e.Name
+	}
+	userStoryKeys := map[int]userStoryKey{}
+	for _, us := range userStories {
+		userStoryKeys[us.ID] = userStoryKey{us.EpicID, us.Name}
+	}
+	externalIDs := map[int]string{}
+	for _, tc := range testCases {
+		if tc.ExternalID != "" && tc.Tbid != "" {
+			externalIDs[tc.ID] = tc.ExternalID
+		}
+	}
+
+	for _, e := range im.lock.Epics {
+		if epicNames[e.ID] != e.Name {
+			index.stale += 1 + len(e.UserStories)
+			continue
+		}
+		index.epics[e.Name] = e.ID
+		for _, us := range e.UserStories {
+			key := userStoryKey{e.ID, us.Name}
+			if found, exists := userStoryKeys[us.ID]; !exists || found != key {
+				index.stale++
+				continue
+			}
+			index.userStories[key] = us.ID
+		}
+	}
+	for _, tc := range im.lock.TestCases {
+		if externalIDs[tc.ID] != tc.ExternalID {
+			index.stale++
+			continue
+		}
+		index.testCases[tc.ExternalID] = tc
+	}
+
+	// elements without valid entry, the first one found like by a search
+	for _, e := range epics {
+		if _, exists := index.epics[e.Name]; !exists {
+			index.epics[e.Name] = e.ID
+		}
+	}
+	for _, us := range userStories {
+		key := userStoryKey{us.EpicID, us.Name}
+		if _, exists := index.userStories[key]; !exists {
+			index.userStories[key] = us.ID
+		}
+	}
+	for _, tc := range testCases {
+		if _, exists := index.found[tc.ExternalID]; !exists && externalIDs[tc.ID] != "" {
+			index.found[tc.ExternalID] = tc.ID
+		}
+	}
+	for autID, tc := range index.testCases {
+		index.found[autID] = tc.ID
+	}
+	return index, nil
+}
+
+// renamedUserStory returns the name of the locked user story a new user
+// story was renamed from: all its locked test cases were in the same user
+// story of the epic before, which is not part of the specs any longer.
+func renamedUserStory(index *lockIndex, epicName string, userStory *UserStory, parsed map[string]bool) string {
+	previous := ""
+	for _, tc := range userStory.TestCases {
+		locked := index.testCases[tc.TestCaseDetails.ExternalID.Value]
+		switch {
+		case locked == nil:
+			continue
+		case locked.Epic != epicName || (previous != "" && locked.UserStory != previous):
+			return ""
+		}
+		previous = locked.UserStory
+	}
+	if parsed[previous] {
+		return ""
+	}
+	return previous
+}
@@ -0,0 +1,49 @@
+package cy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLockCheckAndBind(t *testing.T) {
+	locked := func() *Lock {
+		return &Lock{Version: LockVersion, Host: "https://a", Tenant: "imbus", ProductID: 1,
+			Epics: []*LockedEpic{{Name: "Epic", ID: 2}}}
+	}
+	tests := []struct {
+		name      string
+		lock      *Lock
+		host      string
+		tenant    string
+		productID int
+		mismatch  bool
+	}{
+		{"same product", locked(), "https://a", "imbus", 1, false},
+		{"other host", locked(), "https://b", "imbus", 1, true},
+		{"other tenant", locked(), "https://a", "other", 1, true},
+		{"other product", locked(), "https://a", "imbus", 2, true},
+		{"empty lock", &Lock{Version: LockVersion, Host: "https://a", Tenant: "imbus", ProductID: 1}, "https://b", "imbus", 1, false},
+		{"new lock", &Lock{Version: LockVersion}, "https://b", "imbus", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.lock.check(tt.host, tt.tenant, tt.productID)
+			var lockError *LockError
+			if errors.As(err, &lockError) != tt.mismatch {
+				t.Fatalf("got error %v, want mismatch %t", err, tt.mismatch)
+			}
+			if tt.mismatch && (lockError.Host != "https://a" || lockError.Tenant != "imbus" || lockError.ProductID != 1) {
+				t.Errorf("got %+v, want the product of the lock", lockError)
+			}
+			if bound := tt.lock.bind(tt.host, tt.tenant, tt.productID); bound == tt.mismatch {
+				t.Errorf("got bind %t, want %t", bound, !tt.mismatch)
+			}
+			if tt.lock.Host != tt.host || tt.lock.Tenant != tt.tenant || tt.lock.ProductID != tt.productID {
+				t.Errorf("lock is bound to product %d of %s at %s", tt.lock.ProductID, tt.lock.Tenant, tt.lock.Host)
+			}
+			if tt.mismatch && len(tt.lock.Epics) > 0 {
+				t.Errorf("entries of the other product are kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package cy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ModelVersion version of the model file format.
+const ModelVersion = 1
+
+// Model parsed specs as saved with -dryrun -out. The file can be reviewed or
+// transformed and imported later with the import command. Omitted lists
+// the TBCS_AUTIDs of tests left out of the import, see ParseSpecs.
+type Model struct {
+	Version int          `json:"version"`
+	Epics   []*ModelEpic `json:"epics"`
+	Omitted []string     `json:"omitted,omitempty"`
+}
+
+// ModelEpic epic of a model.
+type ModelEpic struct {
+	Name        string            `json:"name"`
+	UserStories []*ModelUserStory `json:"userStories"`
+}
+
+// ModelUserStory user story of a model.
+type ModelUserStory struct {
+	Name      string           `json:"name"`
+	TestCases []*ModelTestCase `json:"testCases"`
+}
+
+// ModelTestCase test case of a model with the position of the test in its
+// spec file. Title is the title given in the spec, Name the one imported.
+type ModelTestCase struct {
+	Name         string      `json:"name"`
+	Title        string      `json:"title,omitempty"`
+	ExternalID   string      `json:"externalId,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	IsAutomated  bool        `json:"isAutomated"`
+	ToBeReviewed bool        `json:"toBeReviewed"`
+	Skipped      bool        `json:"skipped,omitempty"`
+	Categories   []string    `json:"categories,omitempty"`
+	File         string      `json:"file,omitempty"`
+	Line         int         `json:"line,omitempty"`
+	Column       int         `json:"column,omitempty"`
+	TestSteps    []*TestStep `json:"testSteps"`
+}
+
+// NewModel returns the model of the parsed epics and omitted tests.
+func NewModel(epics []*Epic, omitted []string) *Model {
+	model := &Model{Version: ModelVersion, Epics: []*ModelEpic{}, Omitted: omitted}
+	for _, e := range epics {
+		me := &ModelEpic{Name: e.Name, UserStories: []*ModelUserStory{}}
+		for _, us := range e.UserStories {
+			mus := &ModelUserStory{Name: us.Name, TestCases: []*ModelTestCase{}}
+			for _, tc := range us.TestCases {
+				details := tc.TestCaseDetails
+				mtc := &ModelTestCase{
+					Name:         tc.Name,
+					Title:        tc.Title,
+					IsAutomated:  details.IsAutomated,
+					ToBeReviewed: details.ToBeReviewed,
+					Skipped:      tc.Skipped,
+					Categories:   tc.Categories,
+					File:         filepath.ToSlash(tc.File),
+					Line:         tc.Line,
+					Column:       tc.Column,
+					TestSteps:    tc.TestSteps,
+				}
+				if details.ExternalID != nil {
+					mtc.ExternalID = details.ExternalID.Value
+				}
+				if details.Description != nil {
+					mtc.Description = details.Description.Text
+				}
+				if mtc.TestSteps == nil {
+					mtc.TestSteps = []*TestStep{}
+				}
+				mus.TestCases = append(mus.TestCases, mtc)
+			}
+			me.UserStories = append(me.UserStories, mus)
+		}
+		model.Epics = append(model.Epics, me)
+	}
+	return model
+}
+
+// ToEpics returns the epics of the model ready for import.
+func (m *Model) ToEpics() []*Epic {
+	var epics []*Epic
+	for _, me := range m.Epics {
+		e := &Epic{Name: me.Name}
+		for _, mus := range me.UserStories {
+			us := &UserStory{Name: mus.Name}
+			for _, mtc := range mus.TestCases {
+				us.TestCases = append(us.TestCases, &TestCase{
+					Name:      mtc.Name,
+					TestSteps: mtc.TestSteps,
+					TestCaseDetails: &TestCasePatch{
+						Name:         mtc.Name,
+						Description:  &TestCaseDescription{Text: mtc.Description},
+						IsAutomated:  mtc.IsAutomated,
+						ToBeReviewed: mtc.ToBeReviewed,
+						ExternalID:   &ExternalID{Value: mtc.ExternalID},
+					},
+					Categories: mtc.Categories,
+					Skipped:    mtc.Skipped,
+					Title:      mtc.Title,
+					File:       filepath.FromSlash(mtc.File),
+					Line:       mtc.Line,
+					Column:     mtc.Column,
+					body:       -1,
+				})
+			}
+			e.UserStories = append(e.UserStories, us)
+		}
+		epics = append(epics, e)
+	}
+	return epics
+}
+
+// validate checks that all elements have names and all steps a known block.
+func (m *Model) validate() error {
+	for i, e := range m.Epics {
+		if strings.TrimSpace(e.Name) == "" {
+			return fmt.Errorf("epic %d has no name", i+1)
+		}
+		for j, us := range e.UserStories {
+			if strings.TrimSpace(us.Name) == "" {
+				return fmt.Errorf("user story %d of epic %q has no name", j+1, e.Name)
+			}
+			for k, tc := range us.TestCases {
+				if strings.TrimSpace(tc.Name) == "" {
+					return fmt.Errorf("test case %d of user story %q has no name", k+1, us.Name)
+				}
+				for l, step := range tc.TestSteps {
+					switch {
+					case step == nil || strings.TrimSpace(step.Description) == "":
+						return fmt.Errorf("test step %d of test case %q has no description", l+1, tc.Name)
+					case step.TestStepBlock != PreparationBlock && step.TestStepBlock != TestBlock && step.TestStepBlock != CleanupBlock:
+						return fmt.Errorf("test step %d of test case %q has unknown block %q, valid values are: %s, %s, %s",
+							l+1, tc.Name, step.TestStepBlock, PreparationBlock, TestBlock, CleanupBlock)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isYAML reports whether the file name has a YAML extension.
+func isYAML(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// SaveModel writes the model of the parsed epics and omitted tests to the
+// file, as YAML if its extension is .yml or .yaml, as JSON otherwise.
+func SaveModel(file string, epics []*Epic, omitted []string) error {
+	model := NewModel(epics, omitted)
+	var content []byte
+	if isYAML(file) {
+		content = model.yaml()
+	} else {
+		var err error
+		if content, err = json.MarshalIndent(model, "", "  "); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+// LoadModel reads a JSON model written by SaveModel and returns its epics
+// and omitted tests. YAML models are for review only, they can not be read.
+func LoadModel(file string) ([]*Epic, []string, error) {
+	if isYAML(file) {
+		return nil, nil, fmt.Errorf("%s: YAML models can not be imported, save the model as JSON", file)
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var model Model
+	if err := json.Unmarshal(content, &model); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if model.Version != ModelVersion {
+		return nil, nil, fmt.Errorf("%s: unsupported model version %d, expected %d", file, model.Version, ModelVersion)
+	}
+	if err := model.validate(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return model.ToEpics(), model.Omitted, nil
+}
+
+// yaml returns the model as YAML document with the same keys as the JSON one.
+func (m *Model) yaml() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "version: %d\n", m.Version)
+	yamlList(&b, "", "epics", len(m.Epics))
+	for _, e := range m.Epics {
+		fmt.Fprintf(&b, "  - name: %s\n", yamlString(e.Name))
+		yamlList(&b, "    ", "userStories", len(e.UserStories))
+		for _, us := range e.UserStories {
+			fmt.Fprintf(&b, "      - name: %s\n", yamlString(us.Name))
+			yamlList(&b, "        ", "testCases", len(us.TestCases))
+			for _, tc := range us.TestCases {
+				const indent = "            "
+				fmt.Fprintf(&b, "          - name: %s\n", yamlString(tc.Name))
+				if tc.Title != "" {
+					fmt.Fprintf(&b, indent+"title: %s\n", yamlString(tc.Title))
+				}
+				if tc.ExternalID != "" {
+					fmt.Fprintf(&b, indent+"externalId: %s\n", yamlString(tc.ExternalID))
+				}
+				if tc.Description != "" {
+					fmt.Fprintf(&b, indent+"description: %s\n", yamlString(tc.Description))
+				}
+				fmt.Fprintf(&b, indent+"isAutomated: %t\n", tc.IsAutomated)
+				fmt.Fprintf(&b, indent+"toBeReviewed: %t\n", tc.ToBeReviewed)
+				if tc.Skipped {
+					fmt.Fprintf(&b, indent+"skipped: true\n")
+				}
+				if len(tc.Categories) > 0 {
+					yamlList(&b, indent, "categories", len(tc.Categories))
+					for _, c := range tc.Categories {
+						fmt.Fprintf(&b, indent+"  - %s\n", yamlString(c))
+					}
+				}
+				if tc.File != "" {
+					fmt.Fprintf(&b, indent+"file: %s\n", yamlString(tc.File))
+					fmt.Fprintf(&b, indent+"line: %d\n", tc.Line)
+					fmt.Fprintf(&b, indent+"column: %d\n", tc.Column)
+				}
+				yamlList(&b, indent, "testSteps", len(tc.TestSteps))
+				for _, step := range tc.TestSteps {
+					fmt.Fprintf(&b, indent+"  - testStepBlock: %s\n", yamlString(step.TestStepBlock))
+					fmt.Fprintf(&b, indent+"    description: %s\n", yamlString(step.Description))
+					if step.ExpectedResult != "" {
+						fmt.Fprintf(&b, indent+"    expectedResult: %s\n", yamlString(step.ExpectedResult))
+					}
+				}
+			}
+		}
+	}
+	if len(m.Omitted) > 0 {
+		yamlList(&b, "", "omitted", len(m.Omitted))
+		for _, autID := range m.Omitted {
+			fmt.Fprintf(&b, "  - %s\n", yamlString(autID))
+		}
+	}
+	return b.Bytes()
+}
+
+// yamlList writes the key of a list, an empty list inline.
+func yamlList(b *bytes.Buffer, indent, key string, length int) {
+	if length == 0 {
+		fmt.Fprintf(b, "%s%s: []\n", indent, key)
+	} else {
+		fmt.Fprintf(b, "%s%s:\n", indent, key)
+	}
+}
+
+// yamlString returns a double quoted YAML string, its escapes are a superset of Go's.
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
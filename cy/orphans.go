@@ -0,0 +1,133 @@
+package cy
+
+import (
+	"context"
+	"regexp"
+)
+
+// DefaultManagedPattern matches the external ids of test cases managed by
+// the import, fitting DefaultAutIDPattern.
+const DefaultManagedPattern = "^CY-"
+
+// DefaultObsoleteUserStory user story orphaned test cases are moved to.
+const DefaultObsoleteUserStory = "Obsolete"
+
+// OrphanOptions selects the test cases managed by the import and how those
+// no longer found in the specs are handled. Moved test cases go to the user
+// story UserStory within the epic Epic. Omitted lists the external ids of
+// tests still in the specs but left out of the import, e.g. skipped tests
+// with SkipOmit, they are not orphaned.
+type OrphanOptions struct {
+	Policy    OrphanPolicy
+	Managed   *regexp.Regexp // external ids of managed test cases
+	Epic      string
+	UserStory string
+	Omitted   []string
+}
+
+// OrphanPlan planned action for a managed test case no longer found in the
+// specs, ActionNone only reports it.
+type OrphanPlan struct {
+	Action     Action `json:"action"` // ActionNone, ActionUpdate (not automated), ActionMove or ActionDelete
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	ExternalID string `json:"externalId"`
+}
+
+// planOrphans adds the orphaned test cases to the plan. For moved test cases
+// the obsolete user story is added to the plan too, unless it exists already.
+// Orphans that could not be looked up are only reported.
+func (im *importer) planOrphans(ctx context.Context, plan *Plan, epics []*Epic, options *OrphanOptions) {
+	parsed := map[string]bool{}
+	for _, e := range epics {
+		for _, us := range e.UserStories {
+			for _, tc := range us.TestCases {
+				parsed[tc.TestCaseDetails.ExternalID.Value] = true
+			}
+		}
+	}
+	for _, autID := range plan.Omitted {
+		parsed[autID] = true
+	}
+
+	managed, err := im.client.SearchTestCases(ctx, "", "")
+	if err != nil {
+		im.fail("Orphaned Test Cases", err)
+		return
+	}
+	var obsolete *UserStoryPlan
+	for _, summary := range managed {
+		if summary.ExternalID == "" || parsed[summary.ExternalID] || !options.Managed.MatchString(summary.ExternalID) {
+			continue
+		}
+		orphan := &OrphanPlan{Action: ActionNone, ID: summary.ID, Name: summary.Name, ExternalID: summary.ExternalID}
+		plan.Orphans = append(plan.Orphans, orphan)
+		if options.Policy == OrphanReport {
+			continue
+		}
+		if options.Policy == OrphanDelete {
+			orphan.Action = ActionDelete
+			continue
+		}
+
+		existing, err := im.client.GetTestCase(ctx, summary.ID)
+		if err != nil {
+			im.fail(orphanElement(orphan), err)
+			continue
+		}
+		switch options.Policy {
+		case OrphanUnautomate:
+			if existing.IsAutomated {
+				orphan.Action = ActionUpdate
+			}
+		case OrphanMove:
+			if obsolete == nil {
+				if obsolete, err = im.planUserStory(ctx, plan, options.Epic, options.UserStory); err != nil {
+					im.fail(userStoryElement(options.UserStory), err)
+					return
+				}
+				plan.ObsoleteEpic, plan.ObsoleteUserStory = options.Epic, options.UserStory
+			}
+			if obsolete.ID == 0 || existing.UserStoryID != obsolete.ID {
+				orphan.Action = ActionMove
+			}
+		}
+	}
+}
+
+// planUserStory returns the plan of a user story, adding it and its epic to
+// the plan if they are not part of it yet.
+func (im *importer) planUserStory(ctx context.Context, plan *Plan, epicName, userStoryName string) (*UserStoryPlan, error) {
+	var ep *EpicPlan
+	for _, e := range plan.Epics {
+		if e.Name == epicName {
+			ep = e
+		}
+	}
+	if ep == nil {
+		id, err := im.findEpic(ctx, epicName)
+		if err != nil {
+			return nil, err
+		}
+		ep = &EpicPlan{Action: ActionCreate, ID: id, Name: epicName}
+		if id != 0 {
+			ep.Action = ActionNone
+		}
+		plan.Epics = append(plan.Epics, ep)
+	}
+	for _, us := range ep.UserStories {
+		if us.Name == userStoryName {
+			return us, nil
+		}
+	}
+	id, err := im.findUserStory(ctx, ep.ID, userStoryName)
+	if err != nil {
+		return nil, err
+	}
+	usp := &UserStoryPlan{Action: ActionCreate, ID: id, Name: userStoryName}
+	if id != 0 {
+		usp.Action = ActionNone
+	}
+	ep.UserStories = append(ep.UserStories, usp)
+	return usp, nil
+}
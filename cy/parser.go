@@ -1,35 +1,43 @@
 package cy
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
-	"regexp"
+	"io/ioutil"
 	"strings"
 )
 
-// ParseSpecs parses cypress specs and generates elements for import.
-func ParseSpecs(path string, suffix string, epicName string, verbose bool) (epics []*Epic) {
-	epic := &Epic{
-		Name: epicName,
+// expectedResultSeparator separates the action from the expected result in a log message.
+const expectedResultSeparator = " => "
+
+// ParseSpecs parses cypress specs and generates elements for import. The
+// hierarchy decides how nested describe blocks are mapped onto epics and
+// user stories, epicName is used for all tests not mapped to an own epic.
+// Skipped and pending tests are imported according to the skip policy, the
+// TBCS_AUTIDs of those omitted are returned, so their test cases are not
+// taken as orphaned. Problems found while searching and in the spec files are returned as
+// diagnostics, if any of them is an error the generated elements are
+// incomplete.
+func ParseSpecs(selection *SpecSelection, epicName string, hierarchy Hierarchy, skipPolicy SkipPolicy, verbose bool) (epics []*Epic, omitted []string, diagnostics []Diagnostic) {
+	builder := &modelBuilder{
+		hierarchy:   hierarchy,
+		skipPolicy:  skipPolicy,
+		defaultEpic: epicName,
 	}
 
-	epics = append(epics, epic)
-
-	files := filesInFolder(path, suffix)
+	files, diagnostics := FindSpecs(selection)
+	builder.diagnostics = diagnostics
 
 	for _, v := range files {
 		if verbose {
 			fmt.Println("Scanning: ", v)
 		}
-		us := readFile(v)
-		if us != nil {
-			epic.UserStories = append(epic.UserStories, us)
-		}
+		builder.fileName = v
+		first := len(builder.diagnostics)
+		builder.addNodes(builder.readFile(v), scope{})
+		sortDiagnostics(builder.diagnostics[first:])
 	}
 
-	return
+	return builder.epics, builder.omitted, builder.diagnostics
 }
 
 // PrintResults outputs generated elements.
@@ -39,111 +47,123 @@ func PrintResults(epics []*Epic) {
 		for _, v := range v.UserStories {
 			fmt.Println("  User Story: ", v.Name)
 			for _, v := range v.TestCases {
-				fmt.Println("    Test Case: ", v.Name)
+				if v.Skipped {
+					fmt.Println("    Test Case: ", v.Name, "(skipped)")
+				} else {
+					fmt.Println("    Test Case: ", v.Name)
+				}
+				if len(v.Categories) > 0 {
+					fmt.Println("      Categories: ", strings.Join(v.Categories, ", "))
+				}
 				for _, v := range v.TestSteps {
-					fmt.Println("      Test Step: ", v.Description)
+					switch v.TestStepBlock {
+					case PreparationBlock:
+						fmt.Println("      Preparation Step: ", v.Description)
+					case CleanupBlock:
+						fmt.Println("      Cleanup Step: ", v.Description)
+					default:
+						fmt.Println("      Test Step: ", v.Description)
+					}
+					if v.ExpectedResult != "" {
+						fmt.Println("        Expected Result: ", v.ExpectedResult)
+					}
 				}
 			}
 		}
 	}
 }
 
-func readFile(fileName string) (userStory *UserStory) {
-	file, err := os.Open(fileName)
+func (b *modelBuilder) readFile(fileName string) (nodes []*node) {
+	content, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
-		os.Exit(1)
+		b.diagnostics = append(b.diagnostics, Diagnostic{
+			File:     fileName,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		})
+		return
 	}
-	defer file.Close()
-
-	var tc *TestCase
-	var ts *TestStep
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimLeft(line, " ")
-		if strings.HasPrefix(line, "describe(") {
-			userStory = &UserStory{
-				Name: getEffectiveName(line),
-			}
-		}
-		if strings.HasPrefix(line, "it(") {
-			patchData := &TestCasePatch{
-				Name:         userStory.Name + " " + getEffectiveName(line),
-				Description:  &TestCaseDescription{Text: ""},
-				IsAutomated:  true,
-				ToBeReviewed: true,
-				ExternalID:   &ExternalID{Value: ""},
-			}
-			tc = &TestCase{
-				Name:            userStory.Name + " " + getEffectiveName(line),
-				TestCaseDetails: patchData,
-			}
-			userStory.TestCases = append(userStory.TestCases, tc)
-		}
-		// handle special meta keywords
-		if strings.HasPrefix(line, "TBCS_AUTID") {
-			if tc != nil {
-				tc.TestCaseDetails.ExternalID.Value = getEffectiveMeta("TBCS_AUTID", line)
-			}
-		} else if strings.HasPrefix(line, "TBCS_DESCRIPTION") {
-			if tc != nil {
-				tc.TestCaseDetails.Description.Text = getEffectiveMeta("TBCS_DESCRIPTION", line)
-			}
-		} else if strings.HasPrefix(line, "TBCS_CATEGORY") {
-			if tc != nil {
-				// not handled yet
-			}
-		} else { // normal log entries
-			if strings.HasPrefix(line, "cy.log(") {
-				logString := getEffectiveName(line)
-				ts = &TestStep{
-					Description: logString,
-				}
-				if tc != nil {
-					tc.TestSteps = append(tc.TestSteps, ts)
-				}
-			}
-		}
+	nodes, errs := parseSource(string(content))
+	for _, e := range errs {
+		b.diagnostics = append(b.diagnostics, Diagnostic{
+			File:     fileName,
+			Line:     e.line,
+			Column:   e.column,
+			Severity: SeverityError,
+			Message:  e.message,
+		})
 	}
-
 	return
 }
 
-func getEffectiveMeta(metaKey, line string) (metaValue string) {
-	re := regexp.MustCompile("(" + metaKey + "\\(')(.*)('\\))")
-	match := re.FindStringSubmatch(line)
-
-	if len(match) > 1 {
-		metaValue = match[2]
-	} else {
-		metaValue = ""
+func (b *modelBuilder) createTestCaseFromNode(n *node, userStory *UserStory) (tc *TestCase) {
+	patchData := &TestCasePatch{
+		Name:         userStory.Name + " " + n.title,
+		Description:  &TestCaseDescription{Text: ""},
+		IsAutomated:  true,
+		ToBeReviewed: true,
+		ExternalID:   &ExternalID{Value: ""},
 	}
-	return
-}
-
-func getEffectiveName(line string) (name string) {
-	re := regexp.MustCompile(".*\\('(.*?)'.*")
-	match := re.FindStringSubmatch(line)
-	if len(match) > 0 {
-		name = match[1]
-	} else {
-		name = line
+	tc = &TestCase{
+		Name:            userStory.Name + " " + n.title,
+		TestCaseDetails: patchData,
+		Title:           n.title,
+		File:            b.fileName,
+		Line:            n.line,
+		Column:          n.column,
+		quote:           n.quote,
+		body:            n.body,
 	}
+	b.collectTestCaseContent(n.children, tc)
 	return
 }
 
-func filesInFolder(folder string, suffix string) (files []string) {
-	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
-		if strings.HasSuffix(path, suffix) {
-			files = append(files, path)
+// collectTestCaseContent adds test steps and meta data found anywhere within
+// a test body, e.g. also inside of callbacks like ".then(() => cy.log(...))".
+func (b *modelBuilder) collectTestCaseContent(nodes []*node, tc *TestCase) {
+	for _, n := range nodes {
+		switch n.kind {
+		case logNode:
+			tc.TestSteps = append(tc.TestSteps, newTestStep(TestBlock, n.title))
+		case metaNode:
+			if n.callee != "TBCS_EXPECTED" && len(tc.TestSteps) > 0 {
+				b.violate(n, RuleMetaAfterStep, n.callee+"() should be placed before the first step")
+			}
+			// handle special meta keywords
+			switch n.callee {
+			case "TBCS_AUTID":
+				tc.TestCaseDetails.ExternalID.Value = n.title
+			case "TBCS_DESCRIPTION":
+				tc.TestCaseDetails.Description.Text = n.title
+			case "TBCS_CATEGORY":
+				tc.Categories = mergeCategories(tc.Categories, splitCategories(n.title))
+			case "TBCS_EXPECTED":
+				// expected result of the previous step
+				if len(tc.TestSteps) > 0 {
+					tc.TestSteps[len(tc.TestSteps)-1].ExpectedResult = n.title
+				} else {
+					b.warn(n, "TBCS_EXPECTED() before the first step is ignored")
+				}
+			}
+		case suiteNode, testNode, hookNode:
+			b.warn(n, n.callee+"() inside of a test is ignored")
+			continue
 		}
-		return nil
-	})
-	if err != nil {
-		panic(err)
+		b.collectTestCaseContent(n.children, tc)
 	}
+}
 
-	return
+// newTestStep creates a test step from a log message. An expected result
+// can be given after a separator, e.g. "Click login. => The start page is shown.".
+func newTestStep(block, message string) *TestStep {
+	ts := &TestStep{
+		TestStepBlock: block,
+		Description:   message,
+	}
+	if i := strings.Index(message, expectedResultSeparator); i >= 0 {
+		ts.Description = strings.TrimSpace(message[:i])
+		ts.ExpectedResult = strings.TrimSpace(message[i+len(expectedResultSeparator):])
+	}
+	return ts
 }
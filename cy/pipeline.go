@@ -0,0 +1,72 @@
+package cy
+
+import "sync"
+
+// pipeline runs work on a bounded number of goroutines and the reports of the
+// work in the order it was queued. Reports print and count the outcome, they
+// run one after another so output and summary are never interleaved.
+type pipeline struct {
+	work    chan func()
+	reports chan *task
+	workers sync.WaitGroup
+	done    chan struct{}
+}
+
+// task queued work, finished is closed when report is set.
+type task struct {
+	finished chan struct{}
+	report   func()
+}
+
+func newPipeline(workers int) *pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &pipeline{
+		work:    make(chan func()),
+		reports: make(chan *task, 1024),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for w := range p.work {
+				w()
+			}
+		}()
+	}
+	go func() {
+		defer close(p.done)
+		for t := range p.reports {
+			<-t.finished
+			t.report()
+		}
+	}()
+	return p
+}
+
+// report queues a report without work.
+func (p *pipeline) report(report func()) {
+	t := &task{finished: make(chan struct{}), report: report}
+	close(t.finished)
+	p.reports <- t
+}
+
+// run queues work returning its report, it blocks while all workers are busy.
+func (p *pipeline) run(work func() (report func())) {
+	t := &task{finished: make(chan struct{})}
+	p.reports <- t
+	p.work <- func() {
+		t.report = work()
+		close(t.finished)
+	}
+}
+
+// wait waits until all work is done and reported.
+func (p *pipeline) wait() {
+	close(p.work)
+	p.workers.Wait()
+	close(p.reports)
+	<-p.done
+}
@@ -0,0 +1,352 @@
+package cy
+
+import (
+	"bytes"
+	"context"
+	"cypress-parser/tbcs"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// PlanVersion version of the plan file format.
+const PlanVersion = 1
+
+// Action planned for an element in TestBench CS.
+type Action string
+
+// Planned actions. Epics and user stories are only created, test cases may
+// also be updated or moved to another user story, test steps updated, moved
+// within their block or deleted.
+const (
+	ActionNone   Action = "none"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionMove   Action = "move"
+	ActionDelete Action = "delete"
+)
+
+// Plan changes needed to bring TestBench CS in line with the parsed specs.
+// It is made for one product and can be saved and applied later. Orphans
+// moved away go to the user story ObsoleteUserStory of ObsoleteEpic, which is
+// part of Epics.
+type Plan struct {
+	Version           int           `json:"version"`
+	Host              string        `json:"host"`
+	Tenant            string        `json:"tenant"`
+	ProductID         int           `json:"productId"`
+	Epics             []*EpicPlan   `json:"epics"`
+	Orphans           []*OrphanPlan `json:"orphans,omitempty"`
+	ObsoleteEpic      string        `json:"obsoleteEpic,omitempty"`
+	ObsoleteUserStory string        `json:"obsoleteUserStory,omitempty"`
+	Omitted           []string      `json:"omitted,omitempty"` // external ids of tests in the specs left out of the import
+}
+
+// EpicPlan planned action for an epic, ID is set for existing epics.
+type EpicPlan struct {
+	Action      Action           `json:"action"`
+	ID          int              `json:"id,omitempty"`
+	Name        string           `json:"name"`
+	UserStories []*UserStoryPlan `json:"userStories"`
+}
+
+// UserStoryPlan planned action for a user story, ID is set for existing user
+// stories. RenamedFrom is the user story of the lock a new one replaces.
+type UserStoryPlan struct {
+	Action      Action          `json:"action"`
+	ID          int             `json:"id,omitempty"`
+	Name        string          `json:"name"`
+	RenamedFrom string          `json:"renamedFrom,omitempty"`
+	TestCases   []*TestCasePlan `json:"testCases"`
+}
+
+// TestCasePlan planned action for a test case. For existing test cases ID is
+// set, Fields lists the changed fields and Steps the edits of the test steps.
+// Moved test cases may be changed too. Locked test cases are unchanged since
+// the last import according to the lock and not compared. RenamedFrom and
+// FromUserStory are the name and location of the last import, if the lock
+// knows them.
+type TestCasePlan struct {
+	Action          Action        `json:"action"`
+	ID              int           `json:"id,omitempty"`
+	FromUserStoryID int           `json:"fromUserStoryId,omitempty"` // current user story of moved test cases
+	FromUserStory   string        `json:"fromUserStory,omitempty"`
+	RenamedFrom     string        `json:"renamedFrom,omitempty"`
+	Locked          bool          `json:"locked,omitempty"`
+	Fields          []string      `json:"fields,omitempty"`
+	Steps           []*StepChange `json:"steps,omitempty"`
+	Categories      []string      `json:"categories,omitempty"`
+	TestCase        *TestCase     `json:"testCase"`
+}
+
+// MakePlan logs in to TestBench CS, reads the current state of the product
+// and compares it to the parsed epics. Orphaned test cases are searched if
+// orphan options are given. Nothing is changed, also the lock of the
+// connection is only read. Elements that could not be looked up are left out
+// of the plan and listed in the summary.
+func MakePlan(ctx context.Context, connection *Connection, epics []*Epic, orphans *OrphanOptions) (*Plan, *Summary, error) {
+	im, err := login(ctx, connection, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer im.logout()
+	plan := im.makePlan(ctx, connection.Tenant, epics, orphans)
+	return plan, im.result(), nil
+}
+
+func (im *importer) makePlan(ctx context.Context, tenantName string, epics []*Epic, orphans *OrphanOptions) *Plan {
+	plan := &Plan{Version: PlanVersion, Host: im.client.BaseURL(), Tenant: tenantName, ProductID: im.client.ProductID()}
+	if orphans != nil {
+		plan.Omitted = orphans.Omitted
+	}
+	index := im.lockIndex(ctx, plan)
+	p := newPipeline(im.workers)
+	var queued bytes.Buffer
+	queueCtx := withOutput(ctx, &queued)
+	for _, e := range epics {
+		e := e
+		epicID, err := im.lookupEpic(queueCtx, index, e.Name)
+		im.queueOutput(p, &queued)
+		if err != nil {
+			p.report(func() { im.skip(epicElement(e.Name), err, countTestCases(e.UserStories...)) })
+			continue
+		}
+		ep := &EpicPlan{Action: ActionCreate, ID: epicID, Name: e.Name}
+		if epicID != 0 {
+			ep.Action = ActionNone
+		}
+		parsed := map[string]bool{}
+		for _, us := range e.UserStories {
+			parsed[us.Name] = true
+		}
+		for _, us := range e.UserStories {
+			us := us
+			userStoryID, err := im.lookupUserStory(queueCtx, index, epicID, us.Name)
+			im.queueOutput(p, &queued)
+			if err != nil {
+				p.report(func() { im.skip(userStoryElement(us.Name), err, len(us.TestCases)) })
+				continue
+			}
+			usp := &UserStoryPlan{Action: ActionCreate, ID: userStoryID, Name: us.Name}
+			if userStoryID != 0 {
+				usp.Action = ActionNone
+			} else if index != nil {
+				usp.RenamedFrom = renamedUserStory(index, e.Name, us, parsed)
+			}
+			for _, tc := range us.TestCases {
+				tc := tc
+				p.run(func() func() {
+					var out bytes.Buffer
+					tcp, err := im.planTestCase(withOutput(ctx, &out), index, e.Name, us.Name, userStoryID, tc)
+					return func() {
+						im.out.Write(out.Bytes())
+						if err != nil {
+							im.skip(testCaseElement(tc.Name), err, 1)
+							return
+						}
+						usp.TestCases = append(usp.TestCases, tcp)
+					}
+				})
+			}
+			ep.UserStories = append(ep.UserStories, usp)
+		}
+		plan.Epics = append(plan.Epics, ep)
+	}
+	p.wait()
+	if orphans != nil {
+		im.planOrphans(ctx, plan, epics, orphans)
+	}
+	return plan
+}
+
+// lockIndex binds and verifies the lock, nil if there is no lock or it could
+// not be verified. Elements are searched one by one then.
+func (im *importer) lockIndex(ctx context.Context, plan *Plan) *lockIndex {
+	if im.lock == nil {
+		return nil
+	}
+	im.bindLock(plan.Host, plan.Tenant, plan.ProductID)
+	index, err := im.verifyLock(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Lock file not verified, it is not used:", err)
+		return nil
+	}
+	if index.stale > 0 {
+		fmt.Fprintf(im.out, "Lock file: %d stale entries, looked up again.\n", index.stale)
+	}
+	return index
+}
+
+// lookupEpic returns the id of the epic, 0 if there is none.
+func (im *importer) lookupEpic(ctx context.Context, index *lockIndex, name string) (int, error) {
+	if index != nil {
+		return index.epics[name], nil
+	}
+	return im.findEpic(ctx, name)
+}
+
+// lookupUserStory returns the id of the user story within the epic, 0 if there is none.
+func (im *importer) lookupUserStory(ctx context.Context, index *lockIndex, epicID int, name string) (int, error) {
+	if index != nil {
+		return index.userStories[userStoryKey{epicID, name}], nil
+	}
+	return im.findUserStory(ctx, epicID, name)
+}
+
+func (im *importer) planTestCase(ctx context.Context, index *lockIndex, epicName, userStoryName string, userStoryID int, testCase *TestCase) (*TestCasePlan, error) {
+	tcp := &TestCasePlan{Action: ActionCreate, Categories: testCase.Categories, TestCase: testCase}
+	var existing *tbcs.TestCase
+	var err error
+	if index == nil {
+		existing, err = im.findTestCase(ctx, testCase)
+	} else if autID := testCase.TestCaseDetails.ExternalID.Value; autID != "" {
+		if locked := index.testCases[autID]; locked != nil {
+			if locked.UserStoryID == userStoryID && locked.Epic == epicName && locked.UserStory == userStoryName &&
+				locked.Hash == contentHash(testCase, testCase.Categories) {
+				tcp.Action, tcp.ID, tcp.Locked = ActionNone, locked.ID, true
+				return tcp, nil
+			}
+			if locked.Name != testCase.Name {
+				tcp.RenamedFrom = locked.Name
+			}
+			if locked.UserStoryID != userStoryID {
+				tcp.FromUserStory = locked.Epic + " / " + locked.UserStory
+			}
+		}
+		if id := index.found[autID]; id != 0 {
+			existing, err = im.client.GetTestCase(ctx, id)
+		}
+	}
+	if err != nil || existing == nil {
+		return tcp, err
+	}
+	tcp.ID = existing.ID
+	tcp.Fields = changedFields(existing, testCase)
+	tcp.Steps = stepChanges(existing, testCase)
+	switch {
+	case existing.UserStoryID != userStoryID:
+		tcp.Action = ActionMove
+		tcp.FromUserStoryID = existing.UserStoryID
+	case len(tcp.Fields) > 0 || len(tcp.Steps) > 0:
+		tcp.Action = ActionUpdate
+	default:
+		tcp.Action = ActionNone
+	}
+	return tcp, nil
+}
+
+// Count returns the number of planned test cases with the given action.
+func (p *Plan) Count(action Action) (count int) {
+	for _, e := range p.Epics {
+		for _, us := range e.UserStories {
+			for _, tc := range us.TestCases {
+				if tc.Action == action {
+					count++
+				}
+			}
+		}
+	}
+	return
+}
+
+var planSymbols = map[Action]string{
+	ActionNone:   " ",
+	ActionCreate: "+",
+	ActionUpdate: "~",
+	ActionMove:   ">",
+	ActionDelete: "-",
+}
+
+// PrintPlan writes the planned actions, every element with a leading symbol:
+// '+' create, '~' update, '>' move, '-' delete, ' ' unchanged.
+func PrintPlan(w io.Writer, plan *Plan) {
+	fmt.Fprintf(w, "Plan for %s, workspace %s, product %d:\n", plan.Host, plan.Tenant, plan.ProductID)
+	for _, e := range plan.Epics {
+		fmt.Fprintf(w, "%s Epic: %s\n", planSymbols[e.Action], e.Name)
+		for _, us := range e.UserStories {
+			if us.RenamedFrom != "" {
+				fmt.Fprintf(w, "%s   User Story: %s (renamed from %s)\n", planSymbols[us.Action], us.Name, us.RenamedFrom)
+			} else {
+				fmt.Fprintf(w, "%s   User Story: %s\n", planSymbols[us.Action], us.Name)
+			}
+			for _, tc := range us.TestCases {
+				printTestCasePlan(w, tc)
+			}
+		}
+	}
+	if len(plan.Orphans) > 0 {
+		fmt.Fprintln(w, "Orphaned Test Cases:")
+		for _, o := range plan.Orphans {
+			fmt.Fprintf(w, "%s     %s (%s): %s\n", planSymbols[o.Action], o.Name, o.ExternalID, orphanActions[o.Action])
+		}
+	}
+	fmt.Fprintf(w, "\nTest cases: %d to create, %d to update, %d to move, %d unchanged, %d orphaned.\n",
+		plan.Count(ActionCreate), plan.Count(ActionUpdate), plan.Count(ActionMove), plan.Count(ActionNone), len(plan.Orphans))
+}
+
+var orphanActions = map[Action]string{
+	ActionNone:   "kept",
+	ActionUpdate: "marked as not automated",
+	ActionMove:   "moved to obsolete user story",
+	ActionDelete: "deleted",
+}
+
+func printTestCasePlan(w io.Writer, tc *TestCasePlan) {
+	details := ""
+	switch tc.Action {
+	case ActionCreate:
+		details = fmt.Sprintf(" (%d steps)", len(tc.TestCase.TestSteps))
+	case ActionMove:
+		if tc.FromUserStory != "" {
+			details = fmt.Sprintf(" (from user story %s)", tc.FromUserStory)
+		} else {
+			details = fmt.Sprintf(" (from user story %d)", tc.FromUserStoryID)
+		}
+	}
+	if tc.RenamedFrom != "" {
+		details += fmt.Sprintf(" renamed from %q", tc.RenamedFrom)
+	}
+	if len(tc.Fields) > 0 {
+		details += " changed: " + strings.Join(tc.Fields, ", ")
+	}
+	fmt.Fprintf(w, "%s     Test Case: %s%s\n", planSymbols[tc.Action], tc.TestCase.Name, details)
+	for _, s := range tc.Steps {
+		step := s.Step.Description
+		if s.Step.ExpectedResult != "" {
+			step += expectedResultSeparator + s.Step.ExpectedResult
+		}
+		if s.Action == ActionDelete {
+			fmt.Fprintf(w, "%s       %s: %s\n", planSymbols[s.Action], s.Block, step)
+		} else {
+			fmt.Fprintf(w, "%s       %s %d: %s\n", planSymbols[s.Action], s.Block, s.Position+1, step)
+		}
+	}
+}
+
+// SavePlan writes the plan as JSON file.
+func SavePlan(file string, plan *Plan) error {
+	content, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+// LoadPlan reads a plan written by SavePlan.
+func LoadPlan(file string) (*Plan, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("%s: unsupported plan version %d, expected %d", file, plan.Version, PlanVersion)
+	}
+	return &plan, nil
+}
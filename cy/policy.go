@@ -0,0 +1,60 @@
+package cy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SkipPolicy defines how skipped and pending tests are imported.
+type SkipPolicy string
+
+const (
+	// SkipManual imports skipped tests as not automated test cases.
+	SkipManual SkipPolicy = "manual"
+	// SkipOmit leaves skipped tests out of the import.
+	SkipOmit SkipPolicy = "omit"
+)
+
+// SkipPolicies lists all supported skip policies.
+var SkipPolicies = []SkipPolicy{SkipManual, SkipOmit}
+
+// ParseSkipPolicy returns the skip policy with the given name.
+func ParseSkipPolicy(name string) (SkipPolicy, error) {
+	names := make([]string, len(SkipPolicies))
+	for i, p := range SkipPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("unknown skip policy %q, valid values are: %s", name, strings.Join(names, ", "))
+}
+
+// OrphanPolicy defines how managed test cases no longer found in the specs are handled.
+type OrphanPolicy string
+
+const (
+	// OrphanReport only lists orphaned test cases.
+	OrphanReport OrphanPolicy = "report"
+	// OrphanUnautomate marks orphaned test cases as not automated.
+	OrphanUnautomate OrphanPolicy = "unautomate"
+	// OrphanMove moves orphaned test cases to the obsolete user story.
+	OrphanMove OrphanPolicy = "move"
+	// OrphanDelete deletes orphaned test cases.
+	OrphanDelete OrphanPolicy = "delete"
+)
+
+// OrphanPolicies lists all supported orphan policies.
+var OrphanPolicies = []OrphanPolicy{OrphanReport, OrphanUnautomate, OrphanMove, OrphanDelete}
+
+// ParseOrphanPolicy returns the orphan policy with the given name.
+func ParseOrphanPolicy(name string) (OrphanPolicy, error) {
+	names := make([]string, len(OrphanPolicies))
+	for i, p := range OrphanPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("unknown orphan policy %q, valid values are: %s", name, strings.Join(names, ", "))
+}
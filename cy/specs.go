@@ -0,0 +1,225 @@
+package cy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultExclude patterns of files never parsed, like Cypress does.
+var DefaultExclude = []string{"**/node_modules/**"}
+
+// SpecSelection selects the spec files below the roots that match one of the
+// include patterns and none of the exclude patterns. Patterns are globs
+// relative to the root like in the Cypress configuration: '**' matches any
+// number of folders, '*' and '?' characters within a name, '[abc]' one of
+// the characters and '{js,ts}' one of the alternatives. A pattern without
+// '/' is matched against the file name only. Include patterns starting with
+// '!' exclude files.
+type SpecSelection struct {
+	Roots   []string
+	Include []string
+	Exclude []string
+}
+
+// SuffixPattern returns the pattern matching all files ending with suffix.
+func SuffixPattern(suffix string) string {
+	return "**/*" + strings.NewReplacer("*", "\\*", "?", "\\?", "[", "\\[", "{", "\\{").Replace(suffix)
+}
+
+// SplitPatterns splits a comma separated list of patterns, commas within
+// braces belong to the pattern, e.g. "**/*.cy.{js,ts},!**/fixtures/**".
+func SplitPatterns(list string) (patterns []string) {
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				patterns = appendPattern(patterns, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendPattern(patterns, list[start:])
+}
+
+func appendPattern(patterns []string, pattern string) []string {
+	if pattern = strings.TrimSpace(pattern); pattern != "" {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// FindSpecs returns the selected spec files, sorted per root. Invalid
+// patterns, unreadable folders and roots without any spec are reported.
+func FindSpecs(selection *SpecSelection) (files []string, diagnostics []Diagnostic) {
+	var include, exclude []*glob
+	invalid := func(pattern string, err error) {
+		diagnostics = append(diagnostics, Diagnostic{File: pattern, Severity: SeverityError, Message: "invalid pattern: " + err.Error()})
+	}
+	for _, pattern := range selection.Include {
+		if strings.HasPrefix(pattern, "!") {
+			if g, err := compileGlob(pattern[1:]); err != nil {
+				invalid(pattern, err)
+			} else {
+				exclude = append(exclude, g)
+			}
+		} else if g, err := compileGlob(pattern); err != nil {
+			invalid(pattern, err)
+		} else {
+			include = append(include, g)
+		}
+	}
+	for _, pattern := range append(DefaultExclude, selection.Exclude...) {
+		if g, err := compileGlob(pattern); err != nil {
+			invalid(pattern, err)
+		} else {
+			exclude = append(exclude, g)
+		}
+	}
+	if len(diagnostics) > 0 {
+		return nil, diagnostics
+	}
+
+	found := map[string]bool{}
+	for _, root := range selection.Roots {
+		if _, err := os.Stat(root); err != nil {
+			diagnostics = append(diagnostics, Diagnostic{File: root, Severity: SeverityError, Message: err.Error()})
+			continue
+		}
+		var rootFiles []string
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				diagnostics = append(diagnostics, Diagnostic{File: path, Severity: SeverityError, Message: err.Error()})
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			relative, err := filepath.Rel(root, path)
+			if err != nil || relative == "." {
+				return nil
+			}
+			relative = filepath.ToSlash(relative)
+			if info.IsDir() {
+				if matchAny(exclude, relative+"/") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if matchAny(include, relative) && !matchAny(exclude, relative) && !found[path] {
+				found[path] = true
+				rootFiles = append(rootFiles, path)
+			}
+			return nil
+		})
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{File: root, Severity: SeverityError, Message: err.Error()})
+		} else if len(rootFiles) == 0 {
+			diagnostics = append(diagnostics, Diagnostic{File: root, Severity: SeverityWarning,
+				Message: fmt.Sprintf("no spec files found matching %s", strings.Join(selection.Include, ", "))})
+		}
+		sort.Strings(rootFiles)
+		files = append(files, rootFiles...)
+	}
+	return files, diagnostics
+}
+
+// glob compiled pattern, base patterns are matched against the file name only.
+type glob struct {
+	regexp *regexp.Regexp
+	base   bool
+}
+
+func (g *glob) match(path string) bool {
+	if g.base {
+		path = strings.TrimSuffix(path, "/")
+		path = path[strings.LastIndex(path, "/")+1:]
+	}
+	return g.regexp.MatchString(path)
+}
+
+func matchAny(globs []*glob, path string) bool {
+	for _, g := range globs {
+		if g.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob translates a glob pattern into a regular expression.
+func compileGlob(pattern string) (*glob, error) {
+	pattern = strings.TrimPrefix(pattern, "./")
+	var expr strings.Builder
+	expr.WriteString("^")
+	braces := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '/' && pattern[i:] == "/**":
+			// matches the folder itself too
+			expr.WriteString("(?:/.*)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			atStart := i == 0 || pattern[i-1] == '/'
+			switch {
+			case atStart && strings.HasPrefix(pattern[i:], "**/"):
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			case atStart && i+2 == len(pattern):
+				expr.WriteString(".*")
+				i++
+			default:
+				expr.WriteString("[^/]*")
+				i++
+			}
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in %q", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '{':
+			braces++
+			expr.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			expr.WriteString(")")
+		case c == ',' && braces > 0:
+			expr.WriteString("|")
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if braces > 0 {
+		return nil, fmt.Errorf("missing '}' in %q", pattern)
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	return &glob{regexp: re, base: !strings.Contains(pattern, "/")}, nil
+}
@@ -0,0 +1,169 @@
+package cy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.cy.js", "login.cy.js", true},
+		{"*.cy.js", "e2e/login.cy.js", true},
+		{"*.cy.js", "login.cy.ts", false},
+		{"e2e/*.cy.js", "e2e/login.cy.js", true},
+		{"e2e/*.cy.js", "e2e/admin/login.cy.js", false},
+		{"./e2e/*.cy.js", "e2e/login.cy.js", true},
+		{"**/*.cy.js", "login.cy.js", true},
+		{"**/*.cy.js", "e2e/admin/login.cy.js", true},
+		{"e2e/**/*.cy.js", "e2e/login.cy.js", true},
+		{"e2e/**/*.cy.js", "other/login.cy.js", false},
+		{"e2e/**", "e2e/admin/login.cy.js", true},
+		{"e2e/**", "e2e/", true},
+		{"e2e/**", "e2e2/login.cy.js", false},
+		{"**/node_modules/**", "node_modules/", true},
+		{"**/node_modules/**", "a/node_modules/b/c.js", true},
+		{"e2e/a**.js", "e2e/abc.js", true},
+		{"e2e/a**.js", "e2e/a/c.js", false},
+		{"login.cy.?s", "login.cy.ts", true},
+		{"login.cy.?s", "login.cy.tsx", false},
+		{"**/*.cy.{js,ts}", "e2e/login.cy.js", true},
+		{"**/*.cy.{js,ts}", "e2e/login.cy.ts", true},
+		{"**/*.cy.{js,ts}", "e2e/login.cy.jsx", false},
+		{"{e2e,api}/*.js", "api/login.js", true},
+		{"{e2e,api}/*.js", "ui/login.js", false},
+		{"*.{cy,spec}.{js,ts}", "login.spec.ts", true},
+		{"*.{cy,spec}.{js,ts}", "login.test.ts", false},
+		{"*.{c{y,z},spec}.js", "login.cz.js", true},
+		{"login[0-9].js", "login1.js", true},
+		{"login[0-9].js", "loginx.js", false},
+		{"login[!0-9].js", "loginx.js", true},
+		{"login[!0-9].js", "login1.js", false},
+		{"login[ab].js", "logina.js", true},
+		{"login[ab].js", "loginc.js", false},
+		{"login[ab].js", "login[ab].js", false},
+		{`login\[ab].js`, "login[ab].js", true},
+		{`login\*.js`, "login*.js", true},
+		{`login\*.js`, "login1.js", false},
+		{"login(1).js", "login(1).js", true},
+		{"login+.js", "loginn.js", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			g, err := compileGlob(tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if match := g.match(tt.path); match != tt.match {
+				t.Errorf("got match %t, want %t", match, tt.match)
+			}
+		})
+	}
+}
+
+func TestCompileGlobErrors(t *testing.T) {
+	for _, pattern := range []string{"login[0-9.js", "*.{js,ts", "login[z-a].js"} {
+		t.Run(pattern, func(t *testing.T) {
+			if _, err := compileGlob(pattern); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
+
+func TestSplitPatterns(t *testing.T) {
+	tests := []struct {
+		list     string
+		patterns []string
+	}{
+		{"", nil},
+		{"**/*.cy.js", []string{"**/*.cy.js"}},
+		{"**/*.cy.js, **/*.spec.js", []string{"**/*.cy.js", "**/*.spec.js"}},
+		{"**/*.cy.{js,ts},!**/fixtures/**", []string{"**/*.cy.{js,ts}", "!**/fixtures/**"}},
+		{"*.{a,{b,c}},d", []string{"*.{a,{b,c}}", "d"}},
+		{" , a,,", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			if patterns := SplitPatterns(tt.list); !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Errorf("got %q, want %q", patterns, tt.patterns)
+			}
+		})
+	}
+}
+
+func TestSuffixPattern(t *testing.T) {
+	g, err := compileGlob(SuffixPattern(".func[1].{ts}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !g.match("e2e/login.func[1].{ts}") || g.match("e2e/login.func1.ts") {
+		t.Errorf("suffix is not matched literally")
+	}
+}
+
+func TestFindSpecs(t *testing.T) {
+	root, err := ioutil.TempDir("", "specs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, file := range []string{
+		"e2e/login.cy.js",
+		"e2e/admin/users.cy.ts",
+		"e2e/fixtures/data.cy.js",
+		"e2e/helper.js",
+		"node_modules/lib/lib.cy.js",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		files   []string
+	}{
+		{"all", []string{"**/*.cy.{js,ts}"}, nil,
+			[]string{"e2e/admin/users.cy.ts", "e2e/fixtures/data.cy.js", "e2e/login.cy.js"}},
+		{"negated include", []string{"**/*.cy.{js,ts}", "!**/fixtures/**"}, nil,
+			[]string{"e2e/admin/users.cy.ts", "e2e/login.cy.js"}},
+		{"exclude", []string{"**/*.cy.{js,ts}"}, []string{"e2e/admin/**", "*.ts"},
+			[]string{"e2e/fixtures/data.cy.js", "e2e/login.cy.js"}},
+		{"base name", []string{"*.js"}, nil,
+			[]string{"e2e/fixtures/data.cy.js", "e2e/helper.js", "e2e/login.cy.js"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, diagnostics := FindSpecs(&SpecSelection{Roots: []string{root}, Include: tt.include, Exclude: tt.exclude})
+			if len(diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", diagnostics)
+			}
+			var relative []string
+			for _, file := range files {
+				r, _ := filepath.Rel(root, file)
+				relative = append(relative, filepath.ToSlash(r))
+			}
+			if !reflect.DeepEqual(relative, tt.files) {
+				t.Errorf("got %q, want %q", relative, tt.files)
+			}
+		})
+	}
+
+	_, diagnostics := FindSpecs(&SpecSelection{Roots: []string{root}, Include: []string{"[a.js", "!{a"}})
+	if len(diagnostics) != 2 || diagnostics[0].Severity != SeverityError || diagnostics[1].Severity != SeverityError {
+		t.Errorf("got diagnostics %v, want two invalid patterns", diagnostics)
+	}
+}
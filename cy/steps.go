@@ -0,0 +1,198 @@
+package cy
+
+import (
+	"cypress-parser/tbcs"
+	"strings"
+)
+
+// StepChange planned edit turning the existing steps of a test step block
+// into the parsed ones. Changes have to be applied in the given order, the
+// position is the 0-based index within the block at the time of the change.
+type StepChange struct {
+	Action   Action    `json:"action"` // ActionCreate, ActionUpdate, ActionMove or ActionDelete
+	Block    string    `json:"block"`
+	StepID   int       `json:"stepId,omitempty"` // existing step, not set for created steps
+	Step     *TestStep `json:"step"`             // content after the change, for deleted steps the old content
+	Position int       `json:"position"`         // target position, not used for deleted steps
+}
+
+// diffSteps returns the minimal edits to turn the existing steps of a block
+// into the desired ones. Steps kept unchanged in the same order are not
+// touched, steps found elsewhere are moved, remaining steps are updated in
+// place as far as possible, the rest is deleted or inserted.
+func diffSteps(block string, existing []*tbcs.TestStep, desired []*TestStep) (changes []*StepChange) {
+	assigned := make([]int, len(desired)) // index of the existing step reused for a desired one, -1 for none
+	anchored := make([]bool, len(desired))
+	used := make([]bool, len(existing))
+	for i := range assigned {
+		assigned[i] = -1
+	}
+
+	// keep the longest common subsequence in place
+	for _, pair := range longestCommonSteps(existing, desired) {
+		assigned[pair[1]] = pair[0]
+		anchored[pair[1]] = true
+		used[pair[0]] = true
+	}
+	// reuse identical steps at other positions
+	for d, ts := range desired {
+		if assigned[d] >= 0 {
+			continue
+		}
+		for e, s := range existing {
+			if !used[e] && sameStep(s, ts) {
+				assigned[d], used[e] = e, true
+				break
+			}
+		}
+	}
+	// reuse remaining steps in order for changed content
+	e := 0
+	for d := range desired {
+		if assigned[d] >= 0 {
+			continue
+		}
+		for e < len(existing) && used[e] {
+			e++
+		}
+		if e < len(existing) {
+			assigned[d], used[e] = e, true
+		}
+	}
+
+	// keys of the steps in their order on the server, the index of an
+	// existing step or -1-d for the created step of desired index d
+	var current []int
+	for i, s := range existing {
+		if !used[i] {
+			changes = append(changes, &StepChange{Action: ActionDelete, Block: block, StepID: s.ID,
+				Step: &TestStep{TestStepBlock: block, Description: s.Description, ExpectedResult: s.ExpectedResult}})
+			continue
+		}
+		current = append(current, i)
+	}
+	key := func(d int) int {
+		if assigned[d] >= 0 {
+			return assigned[d]
+		}
+		return -1 - d
+	}
+	indexOf := func(key int) int {
+		for i, k := range current {
+			if k == key {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// place all other steps behind their desired predecessor, the anchored
+	// steps end up in between in the right order without being touched
+	for d, ts := range desired {
+		if anchored[d] {
+			continue
+		}
+		ex := assigned[d]
+		at := len(current)
+		if ex >= 0 {
+			at = indexOf(ex)
+			current = append(current[:at], current[at+1:]...)
+		}
+		position := 0
+		if d > 0 {
+			position = indexOf(key(d-1)) + 1
+		}
+		current = append(current[:position], append([]int{key(d)}, current[position:]...)...)
+		switch {
+		case ex < 0:
+			changes = append(changes, &StepChange{Action: ActionCreate, Block: block, Step: ts, Position: position})
+		case !sameStep(existing[ex], ts):
+			changes = append(changes, &StepChange{Action: ActionUpdate, Block: block, StepID: existing[ex].ID, Step: ts, Position: position})
+		case at != position:
+			changes = append(changes, &StepChange{Action: ActionMove, Block: block, StepID: existing[ex].ID, Step: ts, Position: position})
+		}
+	}
+	return
+}
+
+// longestCommonSteps returns index pairs of existing and desired steps
+// forming the longest common subsequence of equal steps.
+func longestCommonSteps(existing []*tbcs.TestStep, desired []*TestStep) (pairs [][2]int) {
+	lengths := make([][]int, len(existing)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(desired)+1)
+	}
+	for i := len(existing) - 1; i >= 0; i-- {
+		for j := len(desired) - 1; j >= 0; j-- {
+			if sameStep(existing[i], desired[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(existing) && j < len(desired); {
+		switch {
+		case sameStep(existing[i], desired[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return
+}
+
+func sameStep(s *tbcs.TestStep, ts *TestStep) bool {
+	return s.Description == ts.Description && s.ExpectedResult == ts.ExpectedResult
+}
+
+// blockSteps returns the desired steps of a test case for one block.
+func blockSteps(testCase *TestCase, block string) (steps []*TestStep) {
+	for _, ts := range testCase.TestSteps {
+		if ts.TestStepBlock == block || (ts.TestStepBlock == "" && block == TestBlock) {
+			steps = append(steps, ts)
+		}
+	}
+	return
+}
+
+// importedBlocks test step blocks filled by the import.
+var importedBlocks = []string{PreparationBlock, TestBlock, CleanupBlock}
+
+// changedFields returns the names of the imported fields in which the test
+// case in TestBench CS differs from the parsed one.
+func changedFields(existing *tbcs.TestCase, testCase *TestCase) (fields []string) {
+	if existing.Name != testCase.TestCaseDetails.Name {
+		fields = append(fields, "name")
+	}
+	if existing.Description != effectiveDescription(testCase) {
+		fields = append(fields, "description")
+	}
+	if existing.IsAutomated != testCase.TestCaseDetails.IsAutomated {
+		fields = append(fields, "isAutomated")
+	}
+	return
+}
+
+// stepChanges returns the edits of all imported blocks of an existing test case.
+func stepChanges(existing *tbcs.TestCase, testCase *TestCase) (changes []*StepChange) {
+	for _, block := range importedBlocks {
+		changes = append(changes, diffSteps(block, existing.Steps(block), blockSteps(testCase, block))...)
+	}
+	return
+}
+
+// effectiveDescription returns the description imported for a test case,
+// TestBench CS requires one, so "TBD" is used if the spec has none.
+func effectiveDescription(testCase *TestCase) string {
+	if len(strings.TrimSpace(testCase.TestCaseDetails.Description.Text)) == 0 {
+		return "TBD"
+	}
+	return testCase.TestCaseDetails.Description.Text
+}
@@ -0,0 +1,156 @@
+package cy
+
+import (
+	"cypress-parser/tbcs"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// existingSteps returns steps with ids 1, 2, ... and the given descriptions.
+func existingSteps(descriptions string) (steps []*tbcs.TestStep) {
+	for i, d := range strings.Fields(descriptions) {
+		steps = append(steps, &tbcs.TestStep{ID: i + 1, Description: d})
+	}
+	return
+}
+
+func desiredSteps(descriptions string) (steps []*TestStep) {
+	for _, d := range strings.Fields(descriptions) {
+		steps = append(steps, &TestStep{TestStepBlock: TestBlock, Description: d})
+	}
+	return
+}
+
+// applyStepChanges applies the changes like TestBench CS would and returns
+// the resulting descriptions.
+func applyStepChanges(existing []*tbcs.TestStep, changes []*StepChange) string {
+	steps := append([]*tbcs.TestStep{}, existing...)
+	indexOf := func(id int) int {
+		for i, s := range steps {
+			if s.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	insert := func(s *tbcs.TestStep, position int) {
+		steps = append(steps[:position], append([]*tbcs.TestStep{s}, steps[position:]...)...)
+	}
+	for _, c := range changes {
+		switch c.Action {
+		case ActionDelete:
+			i := indexOf(c.StepID)
+			steps = append(steps[:i], steps[i+1:]...)
+		case ActionCreate:
+			insert(&tbcs.TestStep{Description: c.Step.Description}, c.Position)
+		case ActionUpdate, ActionMove:
+			i := indexOf(c.StepID)
+			s := steps[i]
+			steps = append(steps[:i], steps[i+1:]...)
+			s.Description = c.Step.Description
+			insert(s, c.Position)
+		}
+	}
+	var descriptions []string
+	for _, s := range steps {
+		descriptions = append(descriptions, s.Description)
+	}
+	return strings.Join(descriptions, " ")
+}
+
+func TestDiffSteps(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		desired  string
+		changes  []string // action, step id and position
+	}{
+		{"unchanged", "a b c", "a b c", nil},
+		{"empty", "", "", nil},
+		{"all new", "", "a b", []string{"create 0@0", "create 0@1"}},
+		{"all deleted", "a b", "", []string{"delete 1@0", "delete 2@0"}},
+		{"appended", "a b", "a b c", []string{"create 0@2"}},
+		{"inserted", "a c", "a b c", []string{"create 0@1"}},
+		{"removed", "a b c", "a c", []string{"delete 2@0"}},
+		{"changed", "a b c", "a x c", []string{"update 2@1"}},
+		{"moved to front", "a b c", "c a b", []string{"move 3@0"}},
+		{"moved to end", "a b c", "b c a", []string{"move 1@2"}},
+		{"swapped", "a b", "b a", []string{"move 1@1"}},
+		{"reversed", "a b c d", "d c b a", []string{"move 3@3", "move 2@3", "move 1@3"}},
+		{"duplicates", "a a b", "a b a", []string{"move 2@2"}},
+		{"changed and inserted", "a b c", "a x c d", []string{"update 2@1", "create 0@3"}},
+		{"replaced and moved", "a b c", "c y z", []string{"update 1@2", "update 2@2"}},
+		{"fewer and changed", "a b c d", "x d", []string{"delete 2@0", "delete 3@0", "update 1@0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := existingSteps(tt.existing)
+			changes := diffSteps(TestBlock, existing, desiredSteps(tt.desired))
+			var got []string
+			for _, c := range changes {
+				if c.Block != TestBlock {
+					t.Errorf("got block %q, want %q", c.Block, TestBlock)
+				}
+				got = append(got, fmt.Sprintf("%s %d@%d", c.Action, c.StepID, c.Position))
+			}
+			if !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("got changes %v, want %v", got, tt.changes)
+			}
+			if result := applyStepChanges(existingSteps(tt.existing), changes); result != tt.desired {
+				t.Errorf("changes result in %q, want %q", result, tt.desired)
+			}
+		})
+	}
+}
+
+func TestLongestCommonSteps(t *testing.T) {
+	tests := []struct {
+		existing string
+		desired  string
+		pairs    [][2]int
+	}{
+		{"a b c", "a b c", [][2]int{{0, 0}, {1, 1}, {2, 2}}},
+		{"a b c", "x y", nil},
+		{"a b c d", "b d", [][2]int{{1, 0}, {3, 1}}},
+		{"a x b y c", "a b c", [][2]int{{0, 0}, {2, 1}, {4, 2}}},
+		{"c a b", "a b c", [][2]int{{1, 0}, {2, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.existing+" to "+tt.desired, func(t *testing.T) {
+			pairs := longestCommonSteps(existingSteps(tt.existing), desiredSteps(tt.desired))
+			if !reflect.DeepEqual(pairs, tt.pairs) {
+				t.Errorf("got pairs %v, want %v", pairs, tt.pairs)
+			}
+		})
+	}
+}
+
+// TestDiffStepsAll checks all desired sequences of up to four steps from a
+// small alphabet against a few existing blocks.
+func TestDiffStepsAll(t *testing.T) {
+	var sequences []string
+	var generate func(prefix []string)
+	generate = func(prefix []string) {
+		sequences = append(sequences, strings.Join(prefix, " "))
+		if len(prefix) == 4 {
+			return
+		}
+		for _, step := range []string{"a", "b", "c", "x"} {
+			generate(append(prefix[:len(prefix):len(prefix)], step))
+		}
+	}
+	generate(nil)
+	for _, existing := range []string{"", "a", "a b c", "c b a", "a a b", "a b c a"} {
+		for _, desired := range sequences {
+			changes := diffSteps(TestBlock, existingSteps(existing), desiredSteps(desired))
+			if result := applyStepChanges(existingSteps(existing), changes); result != desired {
+				t.Errorf("%q to %q results in %q", existing, desired, result)
+			}
+			if len(changes) > len(strings.Fields(existing))+len(strings.Fields(desired)) {
+				t.Errorf("%q to %q takes %d changes", existing, desired, len(changes))
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package cy
+
+import (
+	"fmt"
+	"io"
+)
+
+// LoginError login to TestBench CS failed, nothing was imported.
+type LoginError struct {
+	Err error
+}
+
+func (e *LoginError) Error() string {
+	return "login failed: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the failed login request.
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+// Failure element that could not be planned or imported.
+type Failure struct {
+	Element string // e.g. `Test Case "Login works"`
+	Err     error
+}
+
+func (f *Failure) String() string {
+	return f.Element + ": " + f.Err.Error()
+}
+
+// Summary outcome of an import. Test cases are counted by their planned
+// action, failed ones are counted as failed only. Skipped test cases were not
+// imported because one of their parents failed or the import was canceled.
+// Retries counts the requests sent again after transient failures.
+type Summary struct {
+	Created   int
+	Updated   int
+	Moved     int
+	Unchanged int
+	Failed    int
+	Skipped   int
+	Retries   int
+	Failures  []*Failure
+}
+
+// HasFailures reports whether anything could not be planned or imported.
+func (s *Summary) HasFailures() bool {
+	return len(s.Failures) > 0 || s.Skipped > 0
+}
+
+// Print writes the test case counts and all failures.
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Test cases: %d created, %d updated, %d moved, %d unchanged, %d failed, %d skipped.\n",
+		s.Created, s.Updated, s.Moved, s.Unchanged, s.Failed, s.Skipped)
+	if s.Retries > 0 {
+		fmt.Fprintf(w, "%d requests retried.\n", s.Retries)
+	}
+	if len(s.Failures) > 0 {
+		fmt.Fprintf(w, "%d failures:\n", len(s.Failures))
+		for _, f := range s.Failures {
+			fmt.Fprintln(w, "  "+f.String())
+		}
+	}
+}
+
+func (s *Summary) count(action Action) {
+	switch action {
+	case ActionCreate:
+		s.Created++
+	case ActionUpdate:
+		s.Updated++
+	case ActionMove:
+		s.Moved++
+	default:
+		s.Unchanged++
+	}
+}
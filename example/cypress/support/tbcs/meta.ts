@@ -2,11 +2,11 @@
 declare function TBCS_CATEGORY(value: string): void;
 declare function TBCS_DESCRIPTION(value: string): void;
 declare function TBCS_AUTID(value: string): void;
+declare function TBCS_EXPECTED(value: string): void;
 
-// INFO: Categories should be handled per test spec file
-globalThis.TBCS_CATEGORY = (value: string) => {
-  cy.log('TBCS_CATEGORY(' + value + ')');
-};
+// Categories can be declared per spec file, per describe block or per test, several at once separated by comma.
+// They are only read by the cy-parser import. Outside of tests no cypress command may be run, so nothing is logged.
+globalThis.TBCS_CATEGORY = (value: string) => {};
 
 globalThis.TBCS_DESCRIPTION = (value: string) => {
   cy.log('TBCS_DESCRIPTION(' + value + ')');
@@ -15,3 +15,8 @@ globalThis.TBCS_DESCRIPTION = (value: string) => {
 globalThis.TBCS_AUTID = (value: string) => {
   cy.log('TBCS_AUTID(' + value + ')');
 };
+
+// Expected result of the previously logged step.
+globalThis.TBCS_EXPECTED = (value: string) => {
+  cy.log('TBCS_EXPECTED(' + value + ')');
+};
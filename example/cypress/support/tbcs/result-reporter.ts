@@ -65,7 +65,7 @@ function startEventListeners() {
       dateTime: moment().toISOString(),
       content: attributes.args[0],
     };
-    var regcat = /((TBCS_AUTID|TBCS_CATEGORY|TBCS_DESCRIPTION)\(.+\))($)/;
+    var regcat = /((TBCS_AUTID|TBCS_CATEGORY|TBCS_DESCRIPTION|TBCS_EXPECTED)\(.+\))($)/;
     if (regcat.test(command.content)) {
       loggedMetaCommands.add(command);
     } else {
@@ -122,7 +122,8 @@ function afterEachTest() {
   });
   var steps: Array<string> = [];
   loggedCommands.forEach(cmd => {
-    steps.push(cmd.content);
+    // an expected result may follow the action separated by ' => '
+    steps.push(String(cmd.content).split(' => ')[0].trim());
   });
   var tbcsTestCase: TestBenchTestCase = {
     name: reportTest.name,
@@ -2,6 +2,8 @@
 var user = '<user name>';
 var password = '<password>';
 
+TBCS_CATEGORY('Smoke');
+
 describe('Login', function () {
   it('page contains specified elements.', () => {
     TBCS_DESCRIPTION('Test that the login page contains required elements.');
@@ -21,11 +23,13 @@ describe('Login', function () {
   it('page can be switched to german language.', () => {
     TBCS_DESCRIPTION('Test that the login page allows switching the language.');
     TBCS_AUTID('CY-SAMPLE-LOGIN-02');
+    TBCS_CATEGORY('Localization');
 
     cy.log('Go to the login page.');
     cy.visit('/login');
 
     cy.log('Click the "german flag button" to switch to the german language.');
+    TBCS_EXPECTED('The page is displayed in german.');
     cy.get('[id=german]').should('be.visible').click();
 
     cy.log('Check that the login button is labeled with "Anmelden" correctly.');
@@ -47,7 +51,7 @@ describe('Login', function () {
     cy.log('Click the login button.');
     cy.get('[id=button_login]').should('be.visible').click();
 
-    cy.log('Check that the customer list is available.');
+    cy.log('Check the start page. => The customer list is available.');
     cy.get('[id=navigationbar_customer_list]').should('be.visible');
   });
 })
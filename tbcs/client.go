@@ -0,0 +1,291 @@
+// Package tbcs is a client for the TestBench CS REST API.
+package tbcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotLoggedIn is returned by all methods but Login if the client has no session.
+var ErrNotLoggedIn = errors.New("not logged in to TestBench CS")
+
+// Options of a client. Requests are sent with HTTPClient if set, otherwise
+// with a client using the TLS options, http.DefaultClient if there are none.
+// Session can be set to use an existing session, otherwise it is set by Login.
+// A session opened by Login is renewed with the same credentials when it
+// expired, OnRelogin is called after each renewal with the context of the
+// request that found the session expired.
+// Failed requests are retried according to Retry, DefaultRetryPolicy if nil,
+// OnRetry is called before each retry with the context of the request.
+// RateLimit limits the requests per second of the client including retries,
+// 0 means unlimited. A client may be used by several goroutines.
+type Options struct {
+	BaseURL    string // e.g. https://cloud01-eu.testbench.com
+	ProductID  int
+	HTTPClient *http.Client
+	TLS        *TLSOptions
+	Session    *Session
+	Retry      *RetryPolicy
+	OnRetry    func(context.Context, *RetryEvent)
+	RateLimit  float64
+	OnRelogin  func(context.Context)
+}
+
+// Session authenticated session of a user in a tenant (workspace).
+type Session struct {
+	Token    string
+	TenantID int
+	UserID   int
+}
+
+// Client for the TestBench CS REST API working on one product.
+type Client struct {
+	retries    int64 // first for 64-bit alignment of atomic access
+	baseURL    string
+	productID  int
+	httpClient *http.Client
+	mutex      sync.Mutex // guards session
+	session    *Session
+	loginMutex sync.Mutex // serializes renewals of the session
+	login      *loginData
+	onRelogin  func(context.Context)
+	retry      RetryPolicy
+	onRetry    func(context.Context, *RetryEvent)
+	limiter    *rateLimiter
+}
+
+// New returns a client built from the options.
+func New(options Options) (*Client, error) {
+	base, err := url.Parse(options.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TestBench CS URL %q: %v", options.BaseURL, err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid TestBench CS URL %q: scheme must be http or https", options.BaseURL)
+	}
+	httpClient := options.HTTPClient
+	if httpClient == nil && options.TLS != nil {
+		if httpClient, err = options.TLS.httpClient(); err != nil {
+			return nil, err
+		}
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	retry := DefaultRetryPolicy
+	if options.Retry != nil {
+		retry = *options.Retry
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(options.BaseURL, "/"),
+		productID:  options.ProductID,
+		httpClient: httpClient,
+		session:    options.Session,
+		retry:      retry,
+		onRetry:    options.OnRetry,
+		limiter:    newRateLimiter(options.RateLimit),
+		onRelogin:  options.OnRelogin,
+	}, nil
+}
+
+// Session returns the current session, nil if not logged in.
+func (c *Client) Session() *Session {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.session
+}
+
+func (c *Client) setSession(session *Session) {
+	c.mutex.Lock()
+	c.session = session
+	c.mutex.Unlock()
+}
+
+// ProductID returns the product the client works on.
+func (c *Client) ProductID() int {
+	return c.productID
+}
+
+// BaseURL returns the TestBench CS URL the client connects to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Retries returns the number of requests sent again after a failure.
+func (c *Client) Retries() int {
+	return int(atomic.LoadInt64(&c.retries))
+}
+
+// Login opens a session for the user in the tenant (workspace), an existing
+// session of the user is closed.
+func (c *Client) Login(ctx context.Context, tenant, user, password string) (*Session, error) {
+	data := &loginData{Force: true, Tenant: tenant, User: user, Password: password}
+	session, err := c.open(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	c.loginMutex.Lock()
+	c.login = data
+	c.loginMutex.Unlock()
+	return session, nil
+}
+
+func (c *Client) open(ctx context.Context, data *loginData) (*Session, error) {
+	var response loginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/tenants/login/session", "", data, &response); err != nil {
+		return nil, err
+	}
+	session := &Session{Token: response.SessionToken, TenantID: response.TenantID, UserID: response.UserID}
+	c.setSession(session)
+	return session, nil
+}
+
+// relogin opens a new session after the expired one was rejected, unless
+// another request did so in the meantime.
+func (c *Client) relogin(ctx context.Context, expired *Session) (*Session, error) {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+	if current := c.Session(); current != nil && current.Token != expired.Token {
+		return current, nil
+	}
+	session, err := c.open(ctx, c.login)
+	if err != nil {
+		return nil, fmt.Errorf("session expired, login failed: %w", err)
+	}
+	if c.onRelogin != nil {
+		c.onRelogin(ctx)
+	}
+	return session, nil
+}
+
+// Logout closes the session. A session already expired counts as closed.
+func (c *Client) Logout(ctx context.Context) error {
+	session := c.Session()
+	if session == nil {
+		return ErrNotLoggedIn
+	}
+	err := c.do(ctx, http.MethodDelete, "/api/tenants/"+strconv.Itoa(session.TenantID)+"/login/session", session.Token, nil, nil)
+	if err == nil || StatusCode(err) == http.StatusUnauthorized {
+		c.setSession(nil)
+		return nil
+	}
+	return err
+}
+
+// productRequest sends a request to a path below the product of the client.
+// A request rejected because the session expired is sent again after a new
+// login, if the session was opened by Login.
+func (c *Client) productRequest(ctx context.Context, method, path string, body, result interface{}) error {
+	session := c.Session()
+	if session == nil {
+		return ErrNotLoggedIn
+	}
+	err := c.do(ctx, method, c.productPath(session, path), session.Token, body, result)
+	if StatusCode(err) != http.StatusUnauthorized || !c.canRelogin() {
+		return err
+	}
+	if session, err = c.relogin(ctx, session); err != nil {
+		return err
+	}
+	return c.do(ctx, method, c.productPath(session, path), session.Token, body, result)
+}
+
+func (c *Client) canRelogin() bool {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+	return c.login != nil
+}
+
+func (c *Client) productPath(session *Session, path string) string {
+	return "/api/tenants/" + strconv.Itoa(session.TenantID) + "/products/" + strconv.Itoa(c.productID) + path
+}
+
+// do sends a request with body as JSON and reads the JSON response into
+// result. Bodies and results are skipped if nil. Responses other than 2xx
+// are returned as *Error. Transient failures are retried.
+func (c *Client) do(ctx context.Context, method, path, token string, body, result interface{}) error {
+	var jsonValue []byte
+	if body != nil {
+		var err error
+		if jsonValue, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	started := time.Now()
+	for attempt := 1; ; attempt++ {
+		data, err := c.send(ctx, method, path, token, jsonValue)
+		if err == nil {
+			if result == nil || len(bytes.TrimSpace(data)) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(data, result); err != nil {
+				return fmt.Errorf("%s %s: invalid response: %v", method, path, err)
+			}
+			return nil
+		}
+
+		delay, retry := c.retry.retryDelay(ctx, method, attempt, started, err)
+		if !retry {
+			return err
+		}
+		atomic.AddInt64(&c.retries, 1)
+		if c.onRetry != nil {
+			c.onRetry(ctx, &RetryEvent{Method: method, Path: path, Attempt: attempt, Delay: delay, Err: err})
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends a request once and returns the response body.
+func (c *Client) send(ctx context.Context, method, path, token string, jsonValue []byte) ([]byte, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	var content io.Reader = http.NoBody
+	if jsonValue != nil {
+		content = bytes.NewReader(jsonValue)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, content)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		request.Header.Set("Authorization", token)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: reading response failed: %w", method, path, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, newError(request, response, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,65 @@
+package tbcs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Error request answered by TestBench CS with a status other than 2xx.
+// FailureType and Message are taken from the failure body, Body keeps it
+// as received. RetryAfter is the delay requested by the server, 0 if none.
+type Error struct {
+	Method      string
+	Path        string
+	StatusCode  int
+	Status      string
+	FailureType string
+	Message     string
+	Body        string
+	RetryAfter  time.Duration
+}
+
+func newError(request *http.Request, response *http.Response, body []byte) *Error {
+	e := &Error{
+		Method:     request.Method,
+		Path:       request.URL.Path,
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Body:       string(body),
+		RetryAfter: retryAfter(response.Header.Get("Retry-After")),
+	}
+	var failure failureResponse
+	if json.Unmarshal(body, &failure) == nil {
+		e.FailureType, e.Message = failure.FailureType, failure.Message
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = e.Body
+	}
+	if message == "" {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, e.Status, message)
+}
+
+// StatusCode returns the HTTP status of a failed request, 0 if the error is
+// not an *Error, e.g. because the server could not be reached.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+type failureResponse struct {
+	FailureType string `json:"failureType"`
+	Message     string `json:"message"`
+}
@@ -0,0 +1,68 @@
+package tbcs
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// CreateExecution creates an execution of a test case and returns its id.
+func (c *Client) CreateExecution(ctx context.Context, testCaseID int) (int, error) {
+	var response createdResponse
+	err := c.productRequest(ctx, http.MethodPost, executionsPath(testCaseID), nil, &response)
+	return response.ExecutionID, err
+}
+
+// SetExecutionResult sets the overall result of an execution.
+func (c *Client) SetExecutionResult(ctx context.Context, testCaseID, executionID int, result ExecutionResult) error {
+	return c.productRequest(ctx, http.MethodPatch, executionPath(testCaseID, executionID), &executionResultPatch{ExecutionResult: result}, nil)
+}
+
+// SetExecutionStatus sets the status of an execution.
+func (c *Client) SetExecutionStatus(ctx context.Context, testCaseID, executionID int, status ExecutionStatus) error {
+	return c.productRequest(ctx, http.MethodPut, executionPath(testCaseID, executionID)+"/status", status, nil)
+}
+
+// SetTestStepResult sets the result of a test step within an execution.
+func (c *Client) SetTestStepResult(ctx context.Context, testCaseID, executionID, testStepID int, result ExecutionResult) error {
+	path := executionPath(testCaseID, executionID) + "/testSteps/" + strconv.Itoa(testStepID) + "/result"
+	return c.productRequest(ctx, http.MethodPut, path, result, nil)
+}
+
+// CreateTestSession creates a test session and returns its id.
+func (c *Client) CreateTestSession(ctx context.Context, name string) (int, error) {
+	var response createdResponse
+	err := c.productRequest(ctx, http.MethodPost, "/planning/sessions/v1", &testSessionData{Name: name}, &response)
+	return response.SessionID, err
+}
+
+// JoinTestSession makes the logged in user an active participant of a test session.
+func (c *Client) JoinTestSession(ctx context.Context, testSessionID int) error {
+	return c.productRequest(ctx, http.MethodPatch, testSessionPath(testSessionID)+"/participant/self/v1", &participantPatch{Active: true}, nil)
+}
+
+// SetTestSessionStatus sets the status of a test session.
+func (c *Client) SetTestSessionStatus(ctx context.Context, testSessionID int, status TestSessionStatus) error {
+	return c.productRequest(ctx, http.MethodPatch, testSessionPath(testSessionID)+"/v1", &testSessionPatch{Status: status}, nil)
+}
+
+// AddExecutionToTestSession assigns an execution of a test case to a test session.
+func (c *Client) AddExecutionToTestSession(ctx context.Context, testSessionID, testCaseID, executionID int) error {
+	executions := &testSessionExecutions{AddExecutions: []*testSessionExecution{{
+		TestCaseIDs: testCaseIDs{TestCaseID: testCaseID},
+		ExecutionID: executionID,
+	}}}
+	return c.productRequest(ctx, http.MethodPatch, testSessionPath(testSessionID)+"/assign/executions/v1", executions, nil)
+}
+
+func executionsPath(testCaseID int) string {
+	return "/executions/testCases/" + strconv.Itoa(testCaseID)
+}
+
+func executionPath(testCaseID, executionID int) string {
+	return executionsPath(testCaseID) + "/executions/" + strconv.Itoa(executionID)
+}
+
+func testSessionPath(testSessionID int) string {
+	return "/planning/sessions/" + strconv.Itoa(testSessionID)
+}
@@ -0,0 +1,162 @@
+package tbcs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy how often and how long failed requests are retried. The delay
+// starts with MinDelay and doubles with each retry up to MaxDelay, a random
+// part of up to half the delay is subtracted to spread retries. A longer
+// Retry-After of the server is honored. No retry is started that would end
+// later than MaxTime after the first attempt of the request.
+type RetryPolicy struct {
+	MaxRetries int // retries per request, 0 disables retries
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+	MaxTime    time.Duration
+}
+
+// DefaultRetryPolicy policy used if the options of a client have none.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	MinDelay:   500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+	MaxTime:    2 * time.Minute,
+}
+
+// RetryEvent failed request that is retried after Delay. Attempt counts the
+// retries of the request starting with 1.
+type RetryEvent struct {
+	Method  string
+	Path    string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+var (
+	jitterMutex sync.Mutex
+	jitter      = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// delay returns the delay before the given retry without Retry-After.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.MinDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	jitterMutex.Lock()
+	defer jitterMutex.Unlock()
+	return delay - time.Duration(jitter.Int63n(int64(delay/2)))
+}
+
+// retryDelay returns the delay before retrying a request failed with err and
+// whether it is retried at all.
+func (p *RetryPolicy) retryDelay(ctx context.Context, method string, attempt int, started time.Time, err error) (time.Duration, bool) {
+	if attempt > p.MaxRetries || ctx.Err() != nil || !retryable(method, err) {
+		return 0, false
+	}
+	delay := p.delay(attempt)
+	var e *Error
+	if errors.As(err, &e) && e.RetryAfter > delay {
+		delay = e.RetryAfter
+	}
+	if time.Since(started)+delay > p.MaxTime {
+		return 0, false
+	}
+	return delay, true
+}
+
+// retryable reports whether a request can be sent again after it failed with
+// err. Requests changing to a given state may be repeated after any transient
+// failure. POST requests creating elements are repeated only if the server
+// did certainly not process them, otherwise an element could be created twice.
+func retryable(method string, err error) bool {
+	switch StatusCode(err) {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	case 0:
+		if notSent(err) {
+			return true
+		}
+		return idempotent(method) && connectionFailed(err)
+	}
+	return false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		// the patches of this client set fields to given values
+		return true
+	}
+	return false
+}
+
+// notSent reports whether the connection could not be opened for a passing
+// reason, e.g. was refused. Unknown hosts stay unknown.
+func notSent(err error) bool {
+	var opError *net.OpError
+	if !errors.As(err, &opError) || opError.Op != "dial" || tlsFailed(err) {
+		return false
+	}
+	var dnsError *net.DNSError
+	return !errors.As(err, &dnsError) || dnsError.IsTimeout || dnsError.IsTemporary
+}
+
+// connectionFailed reports whether the connection broke, i.e. was reset,
+// closed or timed out.
+func connectionFailed(err error) bool {
+	if tlsFailed(err) {
+		return false
+	}
+	var netError net.Error
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netError) && netError.Timeout()
+}
+
+// tlsFailed reports whether the TLS handshake failed, e.g. on an untrusted
+// certificate, which no retry changes.
+func tlsFailed(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCertificate x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var recordHeader tls.RecordHeaderError
+	return errors.As(err, &unknownAuthority) || errors.As(err, &invalidCertificate) || errors.As(err, &hostname) ||
+		errors.As(err, &recordHeader)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as HTTP date.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -0,0 +1,244 @@
+package tbcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 10, MinDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if delay := policy.delay(tt.attempt); delay <= tt.max/2 || delay > tt.max {
+					t.Fatalf("got delay %v, want more than %v up to %v", delay, tt.max/2, tt.max)
+				}
+			}
+		})
+	}
+
+	if delay := (&RetryPolicy{}).delay(1); delay != 0 {
+		t.Errorf("got delay %v without MinDelay, want 0", delay)
+	}
+}
+
+func TestRetryPolicyRetryDelay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, MinDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, MaxTime: time.Minute}
+	unavailable := &Error{StatusCode: http.StatusServiceUnavailable}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		attempt int
+		started time.Time
+		err     error
+		delay   time.Duration // 0 for the jittered delay of the policy
+		retry   bool
+	}{
+		{"first retry", context.Background(), http.MethodGet, 1, time.Now(), unavailable, 0, true},
+		{"last retry", context.Background(), http.MethodPost, 2, time.Now(), unavailable, 0, true},
+		{"too many retries", context.Background(), http.MethodGet, 3, time.Now(), unavailable, 0, false},
+		{"not retryable", context.Background(), http.MethodGet, 1, time.Now(), &Error{StatusCode: http.StatusNotFound}, 0, false},
+		{"canceled", canceled, http.MethodGet, 1, time.Now(), unavailable, 0, false},
+		{"retry after", context.Background(), http.MethodGet, 1, time.Now(),
+			&Error{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}, 5 * time.Second, true},
+		{"wrapped retry after", context.Background(), http.MethodGet, 1, time.Now(),
+			fmt.Errorf("search: %w", &Error{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}), 5 * time.Second, true},
+		{"shorter retry after", context.Background(), http.MethodGet, 1, time.Now(),
+			&Error{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Nanosecond}, 0, true},
+		{"retry after beyond max time", context.Background(), http.MethodGet, 1, time.Now(),
+			&Error{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Minute}, 0, false},
+		{"max time elapsed", context.Background(), http.MethodGet, 1, time.Now().Add(-time.Minute), unavailable, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := policy.retryDelay(tt.ctx, tt.method, tt.attempt, tt.started, tt.err)
+			if retry != tt.retry {
+				t.Fatalf("got retry %t, want %t", retry, tt.retry)
+			}
+			switch {
+			case !retry:
+				if delay != 0 {
+					t.Errorf("got delay %v without retry", delay)
+				}
+			case tt.delay != 0:
+				if delay != tt.delay {
+					t.Errorf("got delay %v, want %v", delay, tt.delay)
+				}
+			case delay <= policy.MinDelay/2 || delay > policy.MinDelay:
+				t.Errorf("got delay %v, want the delay of the policy", delay)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	reset := &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+	unknownHost := &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "tbcs.invalid", IsNotFound: true}}
+	dnsTimeout := &net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", Name: "tbcs.example.com", IsTimeout: true}}
+	timeout := &url.Error{Op: "Get", URL: "https://tbcs.example.com", Err: context.DeadlineExceeded}
+	badURL := &url.Error{Op: "parse", URL: "::", Err: errors.New("missing protocol scheme")}
+
+	// a real request to a server with a certificate the client does not trust
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	_, untrusted := http.Post(server.URL, "application/json", nil)
+	if untrusted == nil {
+		t.Fatal("untrusted certificate accepted")
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		get    bool
+		post   bool
+		delete bool
+	}{
+		{"too many requests", &Error{StatusCode: http.StatusTooManyRequests}, true, true, true},
+		{"unavailable", &Error{StatusCode: http.StatusServiceUnavailable}, true, true, true},
+		{"bad gateway", &Error{StatusCode: http.StatusBadGateway}, true, false, true},
+		{"gateway timeout", &Error{StatusCode: http.StatusGatewayTimeout}, true, false, true},
+		{"internal error", &Error{StatusCode: http.StatusInternalServerError}, false, false, false},
+		{"bad request", &Error{StatusCode: http.StatusBadRequest}, false, false, false},
+		{"unauthorized", &Error{StatusCode: http.StatusUnauthorized}, false, false, false},
+		{"connection refused", fmt.Errorf("post: %w", dial), true, true, true},
+		{"connection reset", reset, true, false, true},
+		{"unexpected end", fmt.Errorf("reading response failed: %w", io.ErrUnexpectedEOF), true, false, true},
+		{"timeout", timeout, true, false, true},
+		{"unknown host", unknownHost, false, false, false},
+		{"dns timeout", dnsTimeout, true, true, true},
+		{"bad url", badURL, false, false, false},
+		{"untrusted certificate", untrusted, false, false, false},
+		{"other error", errors.New("invalid response"), false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for method, want := range map[string]bool{http.MethodGet: tt.get, http.MethodPost: tt.post, http.MethodDelete: tt.delete} {
+				if got := retryable(method, tt.err); got != want {
+					t.Errorf("%s: got %t, want %t", method, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 59 * time.Minute, time.Hour},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if delay := retryAfter(tt.header); delay < tt.min || delay > tt.max {
+				t.Errorf("got %v, want %v to %v", delay, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"id": 42}`)
+		}
+	}))
+	defer server.Close()
+
+	var events []RetryEvent
+	client, err := New(Options{
+		BaseURL: server.URL,
+		Retry:   &RetryPolicy{MaxRetries: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxTime: time.Minute},
+		OnRetry: func(ctx context.Context, event *RetryEvent) { events = append(events, *event) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct{ ID int }
+	if err := client.do(context.Background(), http.MethodGet, "/elements", "", nil, &result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != 42 || requests != 3 || client.Retries() != 2 {
+		t.Errorf("got id %d after %d requests and %d retries, want 42 after 3 requests and 2 retries", result.ID, requests, client.Retries())
+	}
+	if len(events) != 2 || events[0].Attempt != 1 || events[1].Attempt != 2 || events[1].Delay != time.Second ||
+		StatusCode(events[0].Err) != http.StatusServiceUnavailable || events[0].Path != "/elements" {
+		t.Errorf("got retry events %+v", events)
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client, err := New(Options{
+		BaseURL: server.URL,
+		Retry:   &RetryPolicy{MaxRetries: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxTime: time.Minute},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method   string
+		requests int32
+	}{
+		{http.MethodGet, 3},
+		{http.MethodPost, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			err := client.do(context.Background(), tt.method, "/elements", "", nil, nil)
+			if StatusCode(err) != http.StatusBadGateway || requests != tt.requests {
+				t.Errorf("got %v after %d requests, want %d requests", err, requests, tt.requests)
+			}
+		})
+	}
+}
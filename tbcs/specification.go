@@ -0,0 +1,145 @@
+package tbcs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateEpic creates an epic and returns its id.
+func (c *Client) CreateEpic(ctx context.Context, name string) (int, error) {
+	var response createdResponse
+	err := c.productRequest(ctx, http.MethodPost, "/requirements/epics", &newEpic{Name: name}, &response)
+	return response.EpicID, err
+}
+
+// CreateUserStory creates a user story in an epic and returns its id.
+func (c *Client) CreateUserStory(ctx context.Context, epicID int, name string) (int, error) {
+	var response createdResponse
+	err := c.productRequest(ctx, http.MethodPost, "/requirements/userStories", &newUserStory{EpicID: epicID, Name: name}, &response)
+	return response.UserStoryID, err
+}
+
+// CreateTestCase creates a test case of the given type in a user story and returns its id.
+func (c *Client) CreateTestCase(ctx context.Context, userStoryID int, name, testCaseType string) (int, error) {
+	var response createdResponse
+	data := &newTestCase{UserStoryID: userStoryID, Name: name, TestCaseType: testCaseType}
+	err := c.productRequest(ctx, http.MethodPost, "/specifications/testCases", data, &response)
+	return response.TestCaseID, err
+}
+
+// GetTestCase returns a test case including its test steps.
+func (c *Client) GetTestCase(ctx context.Context, testCaseID int) (*TestCase, error) {
+	var testCase TestCase
+	if err := c.productRequest(ctx, http.MethodGet, testCasePath(testCaseID), nil, &testCase); err != nil {
+		return nil, err
+	}
+	return &testCase, nil
+}
+
+// PatchTestCase changes a test case.
+func (c *Client) PatchTestCase(ctx context.Context, testCaseID int, patch *TestCasePatch) error {
+	return c.productRequest(ctx, http.MethodPatch, testCasePath(testCaseID), patch, nil)
+}
+
+// DeleteTestCase deletes a test case.
+func (c *Client) DeleteTestCase(ctx context.Context, testCaseID int) error {
+	return c.productRequest(ctx, http.MethodDelete, testCasePath(testCaseID), nil, nil)
+}
+
+// CreateTestStep creates a test step in the block given by the patch and returns its id.
+func (c *Client) CreateTestStep(ctx context.Context, testCaseID int, step *TestStepPatch) (int, error) {
+	var response createdResponse
+	err := c.productRequest(ctx, http.MethodPost, testCasePath(testCaseID)+"/testSteps", step, &response)
+	return response.TestStepID, err
+}
+
+// PatchTestStep changes the content or position of a test step.
+func (c *Client) PatchTestStep(ctx context.Context, testCaseID, testStepID int, patch *TestStepPatch) error {
+	return c.productRequest(ctx, http.MethodPatch, testStepPath(testCaseID, testStepID), patch, nil)
+}
+
+// DeleteTestStep deletes a test step.
+func (c *Client) DeleteTestStep(ctx context.Context, testCaseID, testStepID int) error {
+	return c.productRequest(ctx, http.MethodDelete, testStepPath(testCaseID, testStepID), nil, nil)
+}
+
+// SetPreconditionMarker marks the preconditions of a test case as empty or not.
+func (c *Client) SetPreconditionMarker(ctx context.Context, testCaseID int, empty bool) error {
+	return c.productRequest(ctx, http.MethodPut, testCasePath(testCaseID)+"/preconditions/emptyMarker", empty, nil)
+}
+
+// GetLabels returns all labels of the product.
+func (c *Client) GetLabels(ctx context.Context) ([]*Label, error) {
+	var labels []*Label
+	err := c.productRequest(ctx, http.MethodGet, "/labels", nil, &labels)
+	return labels, err
+}
+
+// CreateLabel creates a label and returns its id.
+func (c *Client) CreateLabel(ctx context.Context, name string) (int, error) {
+	var response createdResponse
+	err := c.productRequest(ctx, http.MethodPost, "/labels", &Label{Name: name}, &response)
+	return response.LabelID, err
+}
+
+// SetTestCaseLabels replaces the labels of a test case.
+func (c *Client) SetTestCaseLabels(ctx context.Context, testCaseID int, labelIDs []int) error {
+	if labelIDs == nil {
+		labelIDs = []int{}
+	}
+	return c.productRequest(ctx, http.MethodPut, testCasePath(testCaseID)+"/labels", labelIDs, nil)
+}
+
+// SearchEpics returns the epics whose field equals the value, all epics if no field is given.
+func (c *Client) SearchEpics(ctx context.Context, field, value string) (epics []*EpicSummary, err error) {
+	found, err := c.searchElements(ctx, field, value, "Epic")
+	for _, e := range found {
+		if e.EpicSummary != nil {
+			epics = append(epics, e.EpicSummary)
+		}
+	}
+	return epics, err
+}
+
+// SearchUserStories returns the user stories whose field equals the value, all user stories if no field is given.
+func (c *Client) SearchUserStories(ctx context.Context, field, value string) (userStories []*UserStorySummary, err error) {
+	found, err := c.searchElements(ctx, field, value, "UserStory")
+	for _, e := range found {
+		if e.UserStorySummary != nil {
+			userStories = append(userStories, e.UserStorySummary)
+		}
+	}
+	return userStories, err
+}
+
+// SearchTestCases returns the test cases whose field equals the value, all test cases if no field is given.
+func (c *Client) SearchTestCases(ctx context.Context, field, value string) (testCases []*TestCaseSummary, err error) {
+	found, err := c.searchElements(ctx, field, value, "TestCase")
+	for _, e := range found {
+		if e.TestCaseSummary != nil {
+			testCases = append(testCases, e.TestCaseSummary)
+		}
+	}
+	return testCases, err
+}
+
+func (c *Client) searchElements(ctx context.Context, field, value, elementType string) ([]*element, error) {
+	// e.g. /elements?types=TestCase&fieldValue=externalId%3Aequals%3ACY-SAMPLE-LOGIN-01
+	path := "/elements?types=" + elementType
+	if field != "" {
+		path += "&fieldValue=" + url.QueryEscape(field+":equals:"+value)
+	}
+	var response elements
+	err := c.productRequest(ctx, http.MethodGet, path, nil, &response)
+	return response.Elements, err
+}
+
+func testCasePath(testCaseID int) string {
+	return "/specifications/testCases/" + strconv.Itoa(testCaseID)
+}
+
+func testStepPath(testCaseID, testStepID int) string {
+	return testCasePath(testCaseID) + "/testSteps/" + strconv.Itoa(testStepID)
+}
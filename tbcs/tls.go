@@ -0,0 +1,82 @@
+package tbcs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSOptions certificate settings of the connection to TestBench CS. The
+// server certificate is always verified unless Insecure is set. CAFile adds
+// a PEM bundle to the system root certificates, CertFile and KeyFile set a
+// client certificate. MinVersion is one of "1.0", "1.1", "1.2" and "1.3",
+// default is "1.2".
+type TLSOptions struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	MinVersion string
+	Insecure   bool
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Config returns the TLS configuration for the options.
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: o.Insecure}
+
+	if o.MinVersion != "" {
+		version, found := tlsVersions[o.MinVersion]
+		if !found {
+			return nil, fmt.Errorf("unknown TLS version %q, valid values are: 1.0, 1.1, 1.2, 1.3", o.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("client certificate and key file must be given together")
+		}
+		certificate, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// httpClient returns a client using the TLS configuration of the options,
+// the other transport settings are the defaults of net/http.
+func (o *TLSOptions) httpClient() (*http.Client, error) {
+	config, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
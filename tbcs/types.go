@@ -0,0 +1,231 @@
+package tbcs
+
+// Test step blocks of a structured test case.
+const (
+	PreparationBlock = "Preparation"
+	NavigationBlock  = "Navigation"
+	TestBlock        = "Test"
+	ResultCheckBlock = "ResultCheck"
+	CleanupBlock     = "Cleanup"
+)
+
+// StructuredTestCase test case type with test step blocks.
+const StructuredTestCase = "StructuredTestCase"
+
+type loginData struct {
+	Force    bool   `json:"force"`
+	Tenant   string `json:"tenantName"`
+	User     string `json:"login"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	GlobalRoles     []string `json:"globalRoles"`
+	SessionToken    string   `json:"sessionToken"`
+	TenantID        int      `json:"tenantId"`
+	UserID          int      `json:"userId"`
+	VideoLinkServer string   `json:"videoLinkServer"`
+}
+
+type createdResponse struct {
+	EventID     int `json:"eventId"`
+	EpicID      int `json:"epicId"`
+	UserStoryID int `json:"userStoryId"`
+	TestCaseID  int `json:"testCaseId"`
+	TestStepID  int `json:"testStepId"`
+	LabelID     int `json:"labelId"`
+	ExecutionID int `json:"executionId"`
+	SessionID   int `json:"testSessionId"`
+}
+
+type newEpic struct {
+	Name string `json:"name"`
+}
+
+type newUserStory struct {
+	EpicID int    `json:"epicId"`
+	Name   string `json:"name"`
+}
+
+type newTestCase struct {
+	UserStoryID  int    `json:"userStoryId"`
+	Name         string `json:"name"`
+	TestCaseType string `json:"testCaseType"`
+}
+
+// TestCase test case as returned by TestBench CS.
+type TestCase struct {
+	ProductID    int           `json:"productId"`
+	EpicID       int           `json:"epicId"`
+	UserStoryID  int           `json:"userStoryId"`
+	ID           int           `json:"id"`
+	Name         string        `json:"name"`
+	Description  string        `json:"description"`
+	IsAutomated  bool          `json:"isAutomated"`
+	Labels       []int         `json:"labels"` // ids of the assigned labels
+	TestSequence *TestSequence `json:"testSequence"`
+}
+
+// Steps returns the steps of a test step block, nil if there is no such block.
+func (tc *TestCase) Steps(block string) []*TestStep {
+	if tc.TestSequence == nil {
+		return nil
+	}
+	for _, b := range tc.TestSequence.TestStepBlocks {
+		if b.Name == block {
+			return b.Steps
+		}
+	}
+	return nil
+}
+
+// TestSequence test step blocks of a structured test case.
+type TestSequence struct {
+	TestStepBlocks []*TestStepBlock `json:"testStepBlocks"`
+}
+
+// TestStepBlock block of test steps.
+type TestStepBlock struct {
+	ID    int         `json:"id"`
+	Name  string      `json:"name"`
+	Steps []*TestStep `json:"steps"`
+}
+
+// TestStep test step as returned by TestBench CS.
+type TestStep struct {
+	ID             int    `json:"id"`
+	Description    string `json:"description"`
+	ExpectedResult string `json:"expectedResult"`
+}
+
+// TestCasePatch changes of a test case, fields not set are left unchanged.
+// Setting UserStoryID moves the test case.
+type TestCasePatch struct {
+	UserStoryID  int          `json:"userStoryId,omitempty"`
+	Name         *string      `json:"name,omitempty"`
+	Description  *Description `json:"description,omitempty"`
+	IsAutomated  *bool        `json:"isAutomated,omitempty"`
+	ToBeReviewed *bool        `json:"toBeReviewed,omitempty"`
+	ExternalID   *ExternalID  `json:"externalId,omitempty"`
+}
+
+// Description rich text description.
+type Description struct {
+	Text string `json:"text"`
+}
+
+// ExternalID id of a test case in an external system, e.g. a TBCS_AUTID.
+type ExternalID struct {
+	Value string `json:"value"`
+}
+
+// TestStepPatch content and position of a test step to create or change,
+// fields not set are left unchanged. The position is the 0-based index
+// within the test step block, a new step without position is appended.
+type TestStepPatch struct {
+	TestStepBlock  string  `json:"testStepBlock,omitempty"`
+	Description    *string `json:"description,omitempty"`
+	ExpectedResult *string `json:"expectedResult,omitempty"`
+	Position       *int    `json:"position,omitempty"`
+}
+
+// Label label of test cases.
+type Label struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type elements struct {
+	Elements []*element `json:"elements"`
+}
+
+type element struct {
+	TestCaseSummary  *TestCaseSummary  `json:"TestCaseSummary"`
+	EpicSummary      *EpicSummary      `json:"EpicSummary"`
+	UserStorySummary *UserStorySummary `json:"UserStorySummary"`
+}
+
+// EpicSummary epic found by a search.
+type EpicSummary struct {
+	Name string `json:"name"`
+	Tbid string `json:"tbid"`
+	ID   int    `json:"id"`
+}
+
+// UserStorySummary user story found by a search.
+type UserStorySummary struct {
+	Name   string `json:"name"`
+	Tbid   string `json:"tbid"`
+	ID     int    `json:"id"`
+	EpicID int    `json:"epicId"`
+}
+
+// TestCaseSummary test case found by a search.
+type TestCaseSummary struct {
+	Name       string `json:"name"`
+	Tbid       string `json:"tbid"`
+	ID         int    `json:"id"`
+	ExternalID string `json:"externalId"`
+}
+
+// ExecutionResult result of a test case execution or test step.
+type ExecutionResult string
+
+// Execution results.
+const (
+	ResultPending    ExecutionResult = "Pending"
+	ResultPassed     ExecutionResult = "Passed"
+	ResultFailed     ExecutionResult = "Failed"
+	ResultCalculated ExecutionResult = "Calculated"
+)
+
+// ExecutionStatus status of a test case execution.
+type ExecutionStatus string
+
+// Execution states.
+const (
+	StatusNew        ExecutionStatus = "New"
+	StatusInProgress ExecutionStatus = "InProgress"
+	StatusBlocked    ExecutionStatus = "Blocked"
+	StatusPaused     ExecutionStatus = "Paused"
+	StatusFinished   ExecutionStatus = "Finished"
+	StatusClosed     ExecutionStatus = "Closed"
+)
+
+// TestSessionStatus status of a test session.
+type TestSessionStatus string
+
+// Test session states.
+const (
+	SessionInProgress TestSessionStatus = "InProgress"
+	SessionCompleted  TestSessionStatus = "Completed"
+)
+
+type executionResultPatch struct {
+	ExecutionResult ExecutionResult `json:"executionResult"`
+}
+
+type testSessionData struct {
+	Name string `json:"name"`
+}
+
+type testSessionPatch struct {
+	Status TestSessionStatus `json:"status"`
+}
+
+type participantPatch struct {
+	Active bool `json:"active"`
+}
+
+type testSessionExecutions struct {
+	AddExecutions []*testSessionExecution `json:"addExecutions"`
+}
+
+type testSessionExecution struct {
+	TestCaseIDs testCaseIDs `json:"testCaseIds"`
+	ExecutionID int         `json:"executionId"`
+}
+
+type testCaseIDs struct {
+	TestCaseID int `json:"testCaseId"`
+}